@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/everytv/factory-go/factory"
+)
+
+type Comment struct {
+	ID     int
+	PostID int
+}
+
+type Post struct {
+	ID       int
+	Comments []*Comment
+}
+
+var CommentFactory = factory.NewFactory(
+	&Comment{},
+).SeqInt("ID", func(n int) (interface{}, error) {
+	return n, nil
+}).Attr("PostID", func(args factory.Args) (interface{}, error) {
+	// "ID" is declared before "Comments" in PostFactory, so it is already generated.
+	return args.ParentField("ID")
+})
+
+var PostFactory = factory.NewFactory(
+	&Post{},
+).SeqInt("ID", func(n int) (interface{}, error) {
+	return n, nil
+}).SubSliceFactory("Comments", CommentFactory, func() int { return 2 })
+
+func main() {
+	for i := 0; i < 2; i++ {
+		post := PostFactory.MustCreate().(*Post)
+		fmt.Println("Post.ID:", post.ID)
+		for _, comment := range post.Comments {
+			fmt.Println("\tComment.ID:", comment.ID, " Comment.PostID:", comment.PostID)
+		}
+	}
+}
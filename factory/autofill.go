@@ -0,0 +1,47 @@
+package factory
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+type autoFiller struct {
+	seqs []int64 // sequence for each field.
+}
+
+// AutoFill generates values for attributes which have neither a generator nor a default value.
+// Integers and floats are sequential numbers, strings are "<attribute name>-<sequence>",
+// booleans are true, and time.Time is the current time given by WithClock.
+// Other types are left as zero value.
+// It is implemented as a generator registered by DefaultGen.
+func (fa *Factory) AutoFill() *Factory {
+	af := &autoFiller{seqs: make([]int64, fa.numField)}
+	return fa.DefaultGen(af.generate)
+}
+
+func (af *autoFiller) generate(sf reflect.StructField, args Args) (interface{}, error) {
+	tp := sf.Type
+	if tp == timeType {
+		return args.factory().now(), nil
+	}
+
+	idx := sf.Index[0]
+	switch tp.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.ValueOf(af.next(args, idx)).Convert(tp).Interface(), nil
+	case reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(float64(af.next(args, idx))).Convert(tp).Interface(), nil
+	case reflect.String:
+		name := args.factory().attrGens[idx].key
+		return reflect.ValueOf(fmt.Sprintf("%v-%d", name, af.next(args, idx))).Convert(tp).Interface(), nil
+	case reflect.Bool:
+		return reflect.ValueOf(true).Convert(tp).Interface(), nil
+	}
+	return nil, nil
+}
+
+func (af *autoFiller) next(args Args, idx int) int64 {
+	return atomic.AddInt64(args.pipeline().seqs.counter(&af.seqs[idx]), 1)
+}
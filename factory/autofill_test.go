@@ -0,0 +1,100 @@
+package factory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFactoryAutoFill(t *testing.T) {
+	type Status string
+	type User struct {
+		ID        int
+		Code      uint8
+		Name      string
+		Status    Status
+		Score     float64
+		IsActive  bool
+		CreatedAt time.Time
+		Location  string
+		Nickname  string
+		Tags      []string
+		private   int
+	}
+
+	var userFactory = NewFactory(&User{Location: "Tokyo"}).
+		Attr("Nickname", func(args Args) (interface{}, error) {
+			return "blue", nil
+		}).
+		AutoFill()
+
+	for i := 1; i <= 2; i++ {
+		user := userFactory.MustCreate().(*User)
+		if user.ID != i || user.Code != uint8(i) || user.Score != float64(i) {
+			t.Errorf("numbers should be %v, but got %+v", i, user)
+		}
+		if user.Name != "Name-"+string(rune('0'+i)) {
+			t.Errorf("user.Name should be Name-%v, not %v", i, user.Name)
+		}
+		if user.Status != Status("Status-"+string(rune('0'+i))) {
+			t.Errorf("user.Status should be Status-%v, not %v", i, user.Status)
+		}
+		if !user.IsActive {
+			t.Error("user.IsActive should be true")
+		}
+		if user.CreatedAt.IsZero() {
+			t.Error("user.CreatedAt should not be zero")
+		}
+		if user.Location != "Tokyo" {
+			t.Errorf("user.Location should be Tokyo, not %v", user.Location)
+		}
+		if user.Nickname != "blue" {
+			t.Errorf("user.Nickname should be blue, not %v", user.Nickname)
+		}
+		if user.Tags != nil {
+			t.Errorf("user.Tags should be nil, not %v", user.Tags)
+		}
+	}
+}
+
+func TestFactoryDefaultGen(t *testing.T) {
+	type User struct {
+		ID       int
+		Name     string
+		Location string
+		Nickname string
+	}
+
+	var userFactory = NewFactory(&User{Location: "Tokyo"}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		DefaultGen(func(field reflect.StructField, args Args) (interface{}, error) {
+			if field.Type.Kind() == reflect.String {
+				return "fake-" + field.Name, nil
+			}
+			return nil, nil
+		})
+
+	user := userFactory.MustCreateWithOption(map[string]interface{}{"Nickname": "blue"}).(*User)
+	if user.ID != 1 {
+		t.Errorf("user.ID should be 1, not %v", user.ID)
+	}
+	if user.Name != "fake-Name" {
+		t.Errorf("user.Name should be fake-Name, not %v", user.Name)
+	}
+	if user.Location != "Tokyo" {
+		t.Errorf("user.Location should be Tokyo, not %v", user.Location)
+	}
+	if user.Nickname != "blue" {
+		t.Errorf("user.Nickname should be blue, not %v", user.Nickname)
+	}
+
+	userFactory.DefaultGen(func(field reflect.StructField, args Args) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	if _, err := userFactory.Create(); err == nil {
+		t.Error("an error of DefaultGen should abort creation")
+	}
+}
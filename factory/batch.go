@@ -0,0 +1,286 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// WithMaxBatchMemory bounds the memory of objects buffered by CreateChan.
+// The size of each object is estimated by the size of the model type,
+// so memory referenced by pointers, slices, maps and strings isn't counted.
+func (fa *Factory) WithMaxBatchMemory(bytes int64) *Factory {
+	fa.maxMemory = bytes
+	return fa
+}
+
+// CreateChan creates n objects with option and sends them to the returned channel.
+// The error channel receives an error if creation fails, then both channels are closed.
+// Creation stops when ctx is done.
+func (fa *Factory) CreateChan(ctx context.Context, n int, opt map[string]interface{}) (<-chan interface{}, <-chan error) {
+	errCh := make(chan error, 1)
+	if n < 0 {
+		ch := make(chan interface{})
+		errCh <- errors.New("n should not be negative")
+		close(errCh)
+		close(ch)
+		return ch, errCh
+	}
+	ch := make(chan interface{}, fa.chanBufferSize(n))
+	go func() {
+		defer close(errCh)
+		defer close(ch)
+		ctx := withBatchScope(ctx)
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				errCh <- err
+				return
+			}
+			inst, err := fa.create(ctx, opt, nil)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			select {
+			case ch <- inst:
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return ch, errCh
+}
+
+func (fa *Factory) chanBufferSize(n int) int {
+	if fa.maxMemory <= 0 {
+		return n
+	}
+	size := int64(fa.rt.Size())
+	if size == 0 {
+		return n
+	}
+	if buf := fa.maxMemory / size; buf < int64(n) {
+		return int(buf)
+	}
+	return n
+}
+
+// CreateBatchWithOption creates n objects with the same option.
+func (fa *Factory) CreateBatchWithOption(n int, opt map[string]interface{}) ([]interface{}, error) {
+	if n < 0 {
+		return nil, errors.New("n should not be negative")
+	}
+	ctx := withBatchScope(context.Background())
+	insts := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		inst, err := fa.create(ctx, opt, nil)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+	}
+	return insts, nil
+}
+
+// WithTimeField sets the time.Time attribute which CreateTimeline sets timestamps to.
+func (fa *Factory) WithTimeField(name string) *Factory {
+	idx := fa.checkIdx(name)
+	if fa.rt.Field(idx).Type != timeType {
+		panic("Attribute should be time.Time: " + name)
+	}
+	fa.timeField = name
+	return fa
+}
+
+// CreateTimeline creates n objects whose time attribute increases by step from start.
+// Each timestamp is moved randomly within ±jitter, but the order of objects is kept.
+func (fa *Factory) CreateTimeline(n int, start time.Time, step, jitter time.Duration) ([]interface{}, error) {
+	if fa.timeField == "" {
+		return nil, errors.New("time field is not set, use WithTimeField")
+	}
+	if n < 0 {
+		return nil, errors.New("n should not be negative")
+	}
+	if step <= 0 {
+		return nil, errors.New("step should be positive")
+	}
+
+	ctx := withBatchScope(context.Background())
+	insts := make([]interface{}, 0, n)
+	var prev time.Time
+	for i := 0; i < n; i++ {
+		ts := start.Add(time.Duration(i) * step)
+		if jitter > 0 {
+			ts = ts.Add(time.Duration(fa.random().Int63n(int64(2*jitter)+1)) - jitter)
+		}
+		if i > 0 && !ts.After(prev) {
+			ts = prev.Add(time.Nanosecond)
+		}
+		prev = ts
+
+		inst, err := fa.create(ctx, map[string]interface{}{fa.timeField: ts}, nil)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+	}
+	return insts, nil
+}
+
+// CreateBatchUntil creates objects with option until deadline passes,
+// and returns the objects created so far.
+func (fa *Factory) CreateBatchUntil(deadline time.Time, opt map[string]interface{}) ([]interface{}, error) {
+	ctx := withBatchScope(context.Background())
+	var insts []interface{}
+	for time.Now().Before(deadline) {
+		inst, err := fa.create(ctx, opt, nil)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+	}
+	return insts, nil
+}
+
+// CreateForEachEnum creates an object for each of values, setting the value to the attribute.
+// Each value is converted to the type of the attribute.
+func (fa *Factory) CreateForEachEnum(name string, values []interface{}) ([]interface{}, error) {
+	idx := fa.checkIdx(name)
+	ctx := withBatchScope(context.Background())
+	insts := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		cv, err := convertValue(v, fa.rt.Field(idx).Type)
+		if err != nil {
+			return nil, err
+		}
+		inst, err := fa.create(ctx, map[string]interface{}{name: cv.Interface()}, nil)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+	}
+	return insts, nil
+}
+
+// CreateJoin creates leftN objects by leftFa and rightN objects by rightFa,
+// and returns rows made by link for every pair of them.
+// A pair is skipped if link returns nil, so link can sample pairs.
+func CreateJoin(leftN, rightN int, leftFa, rightFa *Factory, link func(l, r interface{}) interface{}) ([]interface{}, error) {
+	lefts, err := leftFa.CreateBatchWithOption(leftN, nil)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := rightFa.CreateBatchWithOption(rightN, nil)
+	if err != nil {
+		return nil, err
+	}
+	var rows []interface{}
+	for _, l := range lefts {
+		for _, r := range rights {
+			if row := link(l, r); row != nil {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// WithMutualRef sets the attributes which CreatePair wires to each other.
+// Both attributes should be able to hold an object created by the factory.
+func (fa *Factory) WithMutualRef(fieldA, fieldB string) *Factory {
+	mt := reflect.TypeOf(fa.model)
+	if mt.Kind() != reflect.Ptr {
+		panic("Model should be a pointer for mutual references")
+	}
+	idxs := []int{fa.checkIdx(fieldA), fa.checkIdx(fieldB)}
+	for i, idx := range idxs {
+		if !mt.AssignableTo(fa.rt.Field(idx).Type) {
+			panic(mt.String() + " is not assignable to " + []string{fieldA, fieldB}[i])
+		}
+	}
+	fa.mutualRef = idxs
+	return fa
+}
+
+// CreatePair creates two objects which refer to each other by the attributes given by WithMutualRef,
+// that is the first attribute of a is b and the second attribute of b is a.
+func (fa *Factory) CreatePair() (a, b interface{}, err error) {
+	if fa.mutualRef == nil {
+		return nil, nil, errors.New("mutual references are not set, use WithMutualRef")
+	}
+	if a, err = fa.create(context.Background(), nil, nil); err != nil {
+		return nil, nil, err
+	}
+	if b, err = fa.create(context.Background(), nil, nil); err != nil {
+		return nil, nil, err
+	}
+	reflect.ValueOf(a).Elem().Field(fa.mutualRef[0]).Set(reflect.ValueOf(b))
+	reflect.ValueOf(b).Elem().Field(fa.mutualRef[1]).Set(reflect.ValueOf(a))
+	return a, b, nil
+}
+
+type batchScopeKey struct{}
+
+// batchScope holds values cached by AttrCached during a batch.
+type batchScope struct {
+	mu     sync.Mutex
+	values map[*attrGenerator]*cachedValue
+}
+
+// cachedValue is a value of an attribute cached by AttrCached.
+// Its own lock allows gen to create objects having other cached attributes.
+type cachedValue struct {
+	mu    sync.Mutex
+	done  bool
+	value interface{}
+}
+
+// withBatchScope returns a context carrying a new batch scope.
+func withBatchScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchScopeKey{}, &batchScope{values: make(map[*attrGenerator]*cachedValue)})
+}
+
+// cached returns the cached value of the attribute, adding an empty one if it isn't cached yet.
+func (scope *batchScope) cached(ag *attrGenerator) *cachedValue {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	cv, ok := scope.values[ag]
+	if !ok {
+		cv = &cachedValue{}
+		scope.values[ag] = cv
+	}
+	return cv
+}
+
+// AttrCached is like Attr, but the generated value is cached and reused by all objects in a batch,
+// that is a call of CreateBatchWithOption, CreateBatchUntil, CreateChan, CreateTimeline or CreateForEachEnum.
+// The cache is discarded when the batch finishes, so each batch calls gen once. Other methods
+// creating a single object call gen every time.
+func (fa *Factory) AttrCached(name string, gen func(Args) (interface{}, error)) *Factory {
+	idx := fa.checkIdx(name)
+	ag := fa.attrGens[idx]
+	return fa.Attr(name, func(args Args) (interface{}, error) {
+		scope, ok := args.Context().Value(batchScopeKey{}).(*batchScope)
+		if !ok {
+			return gen(args)
+		}
+		cv := scope.cached(ag)
+		cv.mu.Lock()
+		defer cv.mu.Unlock()
+		if cv.done {
+			return cv.value, nil
+		}
+		v, err := gen(args)
+		if err != nil {
+			return nil, err
+		}
+		cv.value = v
+		cv.done = true
+		return v, nil
+	})
+}
@@ -0,0 +1,331 @@
+package factory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFactoryCreateChan(t *testing.T) {
+	type User struct {
+		ID int
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	ch, errCh := userFactory.CreateChan(context.Background(), 5, nil)
+	var count int
+	for inst := range ch {
+		count++
+		if id := inst.(*User).ID; id != count {
+			t.Errorf("user.ID should be %v, not %v", count, id)
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+	if count != 5 {
+		t.Errorf("count should be 5, not %v", count)
+	}
+	ch, errCh = userFactory.CreateChan(context.Background(), -1, nil)
+	for range ch {
+		t.Error("no object should be created for negative n")
+	}
+	if err := <-errCh; err == nil {
+		t.Error("negative n should return an error")
+	}
+}
+
+func TestFactoryWithMaxBatchMemory(t *testing.T) {
+	type User struct {
+		ID   int64
+		Code int64
+	}
+
+	var userFactory = NewFactory(&User{})
+	if size := userFactory.chanBufferSize(100); size != 100 {
+		t.Errorf("buffer size should be 100, not %v", size)
+	}
+
+	userFactory.WithMaxBatchMemory(64)
+	if size := userFactory.chanBufferSize(100); size != 4 {
+		t.Errorf("buffer size should be 4, not %v", size)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, errCh := userFactory.CreateChan(ctx, 100, nil)
+	<-ch
+	cancel()
+	for range ch {
+	}
+	if err := <-errCh; err != context.Canceled {
+		t.Errorf("err should be context.Canceled, not %v", err)
+	}
+}
+
+func TestFactoryCreateBatchWithOption(t *testing.T) {
+	type User struct {
+		ID     int
+		Status string
+	}
+
+	var userFactory = NewFactory(&User{Status: "inactive"}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	insts, err := userFactory.CreateBatchWithOption(5, map[string]interface{}{"Status": "active"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insts) != 5 {
+		t.Fatalf("len(insts) should be 5, not %v", len(insts))
+	}
+	for i, inst := range insts {
+		user := inst.(*User)
+		if user.ID != i+1 {
+			t.Errorf("user.ID should be %v, not %v", i+1, user.ID)
+		}
+		if user.Status != "active" {
+			t.Errorf("user.Status should be active, not %v", user.Status)
+		}
+	}
+	if _, err := userFactory.CreateBatchWithOption(-1, nil); err == nil {
+		t.Error("negative n should return an error")
+	}
+}
+
+func TestFactoryCreateBatchUntil(t *testing.T) {
+	type User struct {
+		ID int
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	users, err := userFactory.CreateBatchUntil(time.Now().Add(10*time.Millisecond), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) == 0 {
+		t.Fatal("users should be created")
+	}
+	for i, user := range users {
+		if user.(*User).ID != i+1 {
+			t.Errorf("user.ID should be %v, not %v", i+1, user.(*User).ID)
+		}
+	}
+
+	users, err = userFactory.CreateBatchUntil(time.Now().Add(-time.Second), nil)
+	if err != nil || len(users) != 0 {
+		t.Errorf("no users should be created after the deadline, but got %v, %v", len(users), err)
+	}
+}
+
+func TestFactoryCreateTimeline(t *testing.T) {
+	type Event struct {
+		ID        int
+		CreatedAt time.Time
+	}
+
+	var eventFactory = NewFactory(&Event{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+	if _, err := eventFactory.CreateTimeline(3, time.Now(), time.Minute, 0); err == nil {
+		t.Error("CreateTimeline without time field should return an error")
+	}
+
+	eventFactory.WithTimeField("CreatedAt").WithSeed(1)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	insts, err := eventFactory.CreateTimeline(100, start, time.Minute, 45*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prev time.Time
+	for i, inst := range insts {
+		event := inst.(*Event)
+		base := start.Add(time.Duration(i) * time.Minute)
+		if event.CreatedAt.Before(base.Add(-45*time.Second)) || event.CreatedAt.After(base.Add(45*time.Second)) {
+			t.Errorf("event.CreatedAt %v should be within jitter of %v", event.CreatedAt, base)
+		}
+		if i > 0 && !event.CreatedAt.After(prev) {
+			t.Errorf("event.CreatedAt %v should be after %v", event.CreatedAt, prev)
+		}
+		prev = event.CreatedAt
+	}
+	if _, err := eventFactory.CreateTimeline(-1, start, time.Minute, 0); err == nil {
+		t.Error("negative n should return an error")
+	}
+}
+
+func TestFactoryWithTimeFieldOfInvalidType(t *testing.T) {
+	type Event struct {
+		CreatedAt int64
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithTimeField should panic")
+		}
+	}()
+	NewFactory(&Event{}).WithTimeField("CreatedAt")
+}
+
+func TestFactoryCreateForEachEnum(t *testing.T) {
+	type Status string
+	type Order struct {
+		ID     int
+		Status Status
+	}
+
+	var orderFactory = NewFactory(&Order{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	orders, err := orderFactory.CreateForEachEnum("Status", []interface{}{"pending", Status("paid"), "shipped"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range []Status{"pending", "paid", "shipped"} {
+		if order := orders[i].(*Order); order.ID != i+1 || order.Status != status {
+			t.Errorf("unexpected order: %+v", order)
+		}
+	}
+
+	if _, err := orderFactory.CreateForEachEnum("Status", []interface{}{1.5}); err == nil {
+		t.Error("an inconvertible value should return an error")
+	}
+}
+
+func TestCreateJoin(t *testing.T) {
+	type User struct {
+		ID int
+	}
+	type Group struct {
+		ID int
+	}
+	type Membership struct {
+		UserID, GroupID int
+	}
+
+	seq := func(n int) (interface{}, error) {
+		return n, nil
+	}
+	userFactory := NewFactory(&User{}).SeqInt("ID", seq)
+	groupFactory := NewFactory(&Group{}).SeqInt("ID", seq)
+
+	rows, err := CreateJoin(2, 3, userFactory, groupFactory, func(l, r interface{}) interface{} {
+		m := Membership{l.(*User).ID, r.(*Group).ID}
+		if m.UserID == 2 && m.GroupID == 3 {
+			return nil
+		}
+		return m
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		Membership{1, 1}, Membership{1, 2}, Membership{1, 3},
+		Membership{2, 1}, Membership{2, 2},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("rows should be %v, not %v", expected, rows)
+	}
+}
+
+func TestFactoryCreatePair(t *testing.T) {
+	type User struct {
+		ID         int
+		BestFriend *User
+		Partner    interface{}
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	if _, _, err := userFactory.CreatePair(); err == nil {
+		t.Error("CreatePair without WithMutualRef should return an error")
+	}
+
+	a, b, err := userFactory.WithMutualRef("BestFriend", "Partner").CreatePair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ua, ub := a.(*User), b.(*User)
+	if ua.ID != 1 || ub.ID != 2 {
+		t.Errorf("unexpected IDs: %v, %v", ua.ID, ub.ID)
+	}
+	if ua.BestFriend != ub || ub.Partner != ua {
+		t.Errorf("users should refer to each other: %+v, %+v", ua, ub)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithMutualRef should panic")
+		}
+	}()
+	userFactory.WithMutualRef("BestFriend", "ID")
+}
+
+func TestFactoryAttrCached(t *testing.T) {
+	type User struct {
+		Countries []string
+	}
+
+	var calls int
+	var userFactory = NewFactory(&User{}).
+		AttrCached("Countries", func(args Args) (interface{}, error) {
+			calls++
+			return []string{"jp", "us"}, nil
+		})
+
+	if _, err := userFactory.CreateBatchWithOption(3, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("generator should be called once in a batch, but called %v times", calls)
+	}
+	if _, err := userFactory.CreateBatchWithOption(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("cache should be discarded after a batch, but generator is called %v times", calls)
+	}
+	userFactory.MustCreate()
+	userFactory.MustCreate()
+	if calls != 4 {
+		t.Errorf("generator should be called for each single creation, but called %v times", calls)
+	}
+
+	type Country struct {
+		Code string
+	}
+	type Address struct {
+		Country *Country
+	}
+	var countryFactory = NewFactory(&Country{}).
+		AttrCached("Code", func(args Args) (interface{}, error) {
+			return "jp", nil
+		})
+	var addressFactory = NewFactory(&Address{}).
+		AttrCached("Country", func(args Args) (interface{}, error) {
+			return countryFactory.CreateWithContext(args.Context())
+		})
+	addresses, err := addressFactory.CreateBatchWithOption(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if country := addresses[1].(*Address).Country; country.Code != "jp" {
+		t.Errorf("cached generator should create objects with cached attributes, but got %+v", country)
+	}
+}
@@ -0,0 +1,28 @@
+package factory
+
+// Builder accumulates options of a single creation.
+type Builder struct {
+	fa  *Factory
+	opt map[string]interface{}
+}
+
+// Builder returns a new builder which creates an object by the factory.
+func (fa *Factory) Builder() *Builder {
+	return &Builder{fa: fa, opt: make(map[string]interface{})}
+}
+
+// Set sets the value to the attribute like an option of CreateWithOption.
+func (b *Builder) Set(name string, value interface{}) *Builder {
+	b.opt[name] = value
+	return b
+}
+
+// Create creates a new object with the accumulated options.
+func (b *Builder) Create() (interface{}, error) {
+	return b.fa.CreateWithOption(b.opt)
+}
+
+// MustCreate is like Create, but panics if creation fails.
+func (b *Builder) MustCreate() interface{} {
+	return b.fa.MustCreateWithOption(b.opt)
+}
@@ -0,0 +1,31 @@
+package factory
+
+import (
+	"testing"
+)
+
+func TestFactoryBuilder(t *testing.T) {
+	type Group struct {
+		Name string
+	}
+	type User struct {
+		Name  string
+		Age   int
+		Group *Group
+	}
+
+	var userFactory = NewFactory(&User{Name: "anonymous"}).
+		SubFactory("Group", NewFactory(&Group{Name: "member"}))
+
+	user := userFactory.Builder().
+		Set("Age", 30).
+		Set("Group.Name", "admin").
+		MustCreate().(*User)
+	if user.Name != "anonymous" || user.Age != 30 || user.Group.Name != "admin" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	if _, err := userFactory.Builder().Set("Age", "thirty").Create(); err == nil {
+		t.Error("an invalid value should return an error")
+	}
+}
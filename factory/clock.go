@@ -0,0 +1,40 @@
+package factory
+
+import (
+	"reflect"
+	"time"
+)
+
+// WithClock sets the function returning the current time used by the factory instead of time.Now.
+func (fa *Factory) WithClock(clock func() time.Time) *Factory {
+	fa.clock = clock
+	return fa
+}
+
+func (fa *Factory) now() time.Time {
+	if fa.clock == nil {
+		return time.Now()
+	}
+	return fa.clock()
+}
+
+// WithCreatedUpdated sets the same current time to both time.Time attributes on each creation.
+// The current time is given by WithClock.
+func (fa *Factory) WithCreatedUpdated(createdField, updatedField string) *Factory {
+	createdIdx := fa.checkIdx(createdField)
+	updatedIdx := fa.checkIdx(updatedField)
+	for idx, name := range map[int]string{createdIdx: createdField, updatedIdx: updatedField} {
+		if fa.rt.Field(idx).Type != timeType {
+			panic("Attribute should be time.Time: " + name)
+		}
+	}
+	fa.dependOn(updatedIdx, createdIdx)
+
+	fa.setGen(createdIdx, func(args Args) (interface{}, error) {
+		return args.factory().now(), nil
+	})
+	fa.setGen(updatedIdx, func(args Args) (interface{}, error) {
+		return reflect.Indirect(reflect.ValueOf(args.Instance())).Field(createdIdx).Interface(), nil
+	})
+	return fa
+}
@@ -0,0 +1,42 @@
+package factory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFactoryWithCreatedUpdated(t *testing.T) {
+	type Post struct {
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		Title     string
+		DeletedAt time.Time
+	}
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var postFactory = NewFactory(&Post{}).
+		WithCreatedUpdated("CreatedAt", "UpdatedAt").
+		WithClock(func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		})
+
+	for i := 1; i <= 2; i++ {
+		post := postFactory.MustCreate().(*Post)
+		expected := time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC)
+		if !post.CreatedAt.Equal(expected) || post.UpdatedAt != post.CreatedAt {
+			t.Errorf("unexpected timestamps: %v, %v", post.CreatedAt, post.UpdatedAt)
+		}
+	}
+
+	if post := NewFactory(&Post{}).WithCreatedUpdated("CreatedAt", "UpdatedAt").MustCreate().(*Post); post.UpdatedAt != post.CreatedAt {
+		t.Errorf("post.UpdatedAt should be %v, not %v", post.CreatedAt, post.UpdatedAt)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithCreatedUpdated should panic")
+		}
+	}()
+	NewFactory(&Post{}).WithCreatedUpdated("CreatedAt", "Title")
+}
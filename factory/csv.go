@@ -0,0 +1,61 @@
+package factory
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// CreateFromCSV creates an object for each row of CSV.
+// header is the attribute names for each column. If header is nil, the first row is used as header.
+// Attributes that aren't in header are filled by generators.
+func (fa *Factory) CreateFromCSV(r io.Reader, header []string) ([]interface{}, error) {
+	cr := csv.NewReader(r)
+	if header == nil {
+		row, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		header = row
+	}
+
+	indexes := make([]int, len(header))
+	for i, name := range header {
+		idx, ok := fa.nameIndexMap[name]
+		if !ok {
+			return nil, fmt.Errorf("No such attribute name: %v", name)
+		}
+		indexes[i] = idx
+	}
+
+	var insts []interface{}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) != len(header) {
+			return nil, fmt.Errorf("row has %v columns, but header has %v", len(row), len(header))
+		}
+
+		opt := make(map[string]interface{}, len(header))
+		for i, col := range row {
+			v, err := convertString(col, fa.rt.Field(indexes[i]).Type)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %v: %v", header[i], err)
+			}
+			opt[header[i]] = v.Interface()
+		}
+
+		inst, err := fa.create(context.Background(), opt, nil)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+	}
+	return insts, nil
+}
@@ -0,0 +1,47 @@
+package factory
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFactoryCreateFromCSV(t *testing.T) {
+	type User struct {
+		ID      int
+		Name    string `factory:"name"`
+		Age     int    `factory:"age"`
+		IsAdmin bool   `factory:"is_admin"`
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	insts, err := userFactory.CreateFromCSV(strings.NewReader("name,age,is_admin\nbluele,20,true\njun,30,false\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insts) != 2 {
+		t.Fatalf("len(insts) should be 2, not %v", len(insts))
+	}
+	user := insts[1].(*User)
+	if user.ID != 2 || user.Name != "jun" || user.Age != 30 || user.IsAdmin {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	insts, err = userFactory.CreateFromCSV(strings.NewReader("bluele,20\n"), []string{"name", "age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user := insts[0].(*User); user.Name != "bluele" || user.Age != 20 {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	if _, err := userFactory.CreateFromCSV(strings.NewReader("bluele,twenty\n"), []string{"name", "age"}); err == nil {
+		t.Error("an invalid value should return an error")
+	}
+	if _, err := userFactory.CreateFromCSV(strings.NewReader("bluele\n"), []string{"nickname"}); err == nil {
+		t.Error("an unknown column should return an error")
+	}
+}
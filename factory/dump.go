@@ -0,0 +1,69 @@
+package factory
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// Dump creates a new object with option and formats its attributes as an aligned table for debugging.
+// Attributes of nested structs are shown indented under the attribute,
+// and a pointer back to an enclosing struct is shown as <cycle>.
+func (fa *Factory) Dump(opt map[string]interface{}) (string, error) {
+	inst, err := fa.CreateWithOption(opt)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	rv := reflect.ValueOf(inst)
+	visiting := make(map[uintptr]bool)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		visiting[rv.Pointer()] = true
+	}
+	rv = reflect.Indirect(rv)
+	if rv.Kind() == reflect.Struct {
+		dumpStruct(w, rv, 0, visiting)
+	} else {
+		fmt.Fprintf(w, "%v\n", rv)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// dumpStruct writes the attributes of rv. visiting holds the pointers of the structs being written.
+func dumpStruct(w *tabwriter.Writer, rv reflect.Value, depth int, visiting map[uintptr]bool) {
+	indent := strings.Repeat("  ", depth)
+	for i := 0; i < rv.NumField(); i++ {
+		name := rv.Type().Field(i).Name
+		fv := rv.Field(i)
+		sv := fv
+		if sv.Kind() == reflect.Interface && !sv.IsNil() {
+			sv = sv.Elem()
+		}
+		ptr := sv
+		if sv.Kind() == reflect.Ptr && !sv.IsNil() {
+			if visiting[sv.Pointer()] {
+				fmt.Fprintf(w, "%v%v\t<cycle>\n", indent, name)
+				continue
+			}
+			sv = sv.Elem()
+		}
+		if sv.Kind() == reflect.Struct && sv.Type() != timeType {
+			fmt.Fprintf(w, "%v%v\t\n", indent, name)
+			if ptr.Kind() == reflect.Ptr {
+				visiting[ptr.Pointer()] = true
+			}
+			dumpStruct(w, sv, depth+1, visiting)
+			if ptr.Kind() == reflect.Ptr {
+				delete(visiting, ptr.Pointer())
+			}
+			continue
+		}
+		fmt.Fprintf(w, "%v%v\t%v\n", indent, name, fv)
+	}
+}
@@ -0,0 +1,77 @@
+package factory
+
+import (
+	"testing"
+)
+
+func TestFactoryDump(t *testing.T) {
+	type Group struct {
+		ID   int
+		Name string
+	}
+	type User struct {
+		ID        int
+		Name      string
+		Group     *Group
+		Secondary *Group
+	}
+
+	var userFactory = NewFactory(&User{ID: 1, Name: "bluele"}).
+		SubFactory("Group", NewFactory(&Group{ID: 2, Name: "admin"}))
+
+	s, err := userFactory.Dump(map[string]interface{}{"Name": "jun"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n" + s)
+	expected := "ID         1\n" +
+		"Name       jun\n" +
+		"Group      \n" +
+		"  ID       2\n" +
+		"  Name     admin\n" +
+		"Secondary  <nil>\n"
+	if s != expected {
+		t.Errorf("dump should be\n%v\nnot\n%v", expected, s)
+	}
+}
+
+func TestFactoryDumpCycle(t *testing.T) {
+	type User struct {
+		Name  string
+		Group *testDumpGroup
+	}
+	var groupFactory = NewFactory(&testDumpGroup{}).
+		Attr("Owner", func(args Args) (interface{}, error) {
+			return args.Parent().Instance(), nil
+		})
+	var userFactory = NewFactory(&User{Name: "bluele"}).
+		SubFactory("Group", groupFactory)
+
+	s, err := userFactory.Dump(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Name     bluele\n" +
+		"Group    \n" +
+		"  Name   \n" +
+		"  Owner  <cycle>\n"
+	if s != expected {
+		t.Errorf("dump should be\n%v\nnot\n%v", expected, s)
+	}
+
+	var intFactory = NewScalarFactory(0, func(args Args) (interface{}, error) {
+		return 7, nil
+	})
+	s, err = intFactory.Dump(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "7\n" {
+		t.Errorf("dump of a scalar should be 7, not %q", s)
+	}
+}
+
+type testDumpGroup struct {
+	Name  string
+	Owner interface{}
+}
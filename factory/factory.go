@@ -1,41 +1,103 @@
 package factory
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
+	"runtime/debug"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var (
-	TagName    = "factory"
-	emptyValue = reflect.Value{}
+	TagName     = "factory"
+	emptyValue  = reflect.Value{}
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
 )
 
+// ErrCycleDetected is returned when a sub factory, which isn't recursive, creates
+// an object by a factory already creating one of its ancestors.
+var ErrCycleDetected = errors.New("cycle of factories detected")
+
 type Factory struct {
-	model        interface{}
-	numField     int
-	rt           reflect.Type
-	rv           *reflect.Value
-	attrGens     []*attrGenerator
-	nameIndexMap map[string]int // pair for attribute name and field index.
-	isPtr        bool
-	onCreate     func(Args) error
+	model            interface{}
+	numField         int
+	rt               reflect.Type
+	rv               *reflect.Value
+	attrGens         []*attrGenerator
+	nameIndexMap     map[string]int // pair for attribute name and field index.
+	isPtr            bool
+	order            []int // field indexes in the order given by SetOrder.
+	sorted           []int // field indexes in the order generators are applied.
+	beforeCreate     func(Args) error
+	onCreate         func(Args) error
+	scalarGen        func(Args) (interface{}, error) // generator for a non-struct model.
+	jsonFuncs        map[string]func(interface{}) (json.RawMessage, error)
+	maxMemory        int64 // upper bound of bytes buffered by CreateChan.
+	rand             *lockedRand
+	abstract         bool
+	metrics          *metricsRecorder
+	required         []int // field indexes which should not be zero value after build.
+	firstCreate      *onceHook
+	recoverHooks     bool
+	timeField        string // attribute set by CreateTimeline.
+	onError          func(error)
+	defaultGen       func(reflect.StructField, Args) (interface{}, error)
+	validator        func(interface{}) error
+	traits           map[string]*trait
+	traitHooks       []func(Args) error // hooks of traits applied to the factory.
+	onRecursionLimit func(string)
+	strictWiring     bool
+	mutualRef        []int // field indexes wired to each other by CreatePair.
+	typeTransforms   []typeTransform
+	tagName          string // name of the struct tag read by the factory.
+	replayLog        *replayLog
+	clock            func() time.Time
+	seqs             *seqScope // counters used instead of the declared ones, set by withFreshSequences.
+}
+
+type typeTransform struct {
+	tp reflect.Type
+	fn func(interface{}) interface{}
+}
+
+type onceHook struct {
+	once sync.Once
+	fn   func() error
 }
 
 type Args interface {
 	Instance() interface{}
 	Parent() Args
+	ParentField(name string) (interface{}, error)
+	ParentSnapshot() interface{}
 	Context() context.Context
 	UpdateContext(context.Context)
-	pipeline(int) *pipeline
+	Deadline() (time.Time, bool)
+	pipeline() *pipeline
+	factory() *Factory
+	option(string) (interface{}, bool)
+	groupSeq(*int64) int64
 }
 
 type argsStruct struct {
-	ctx context.Context
-	rv  *reflect.Value
-	pl  *pipeline
+	ctx  context.Context
+	rv   *reflect.Value
+	pl   *pipeline
+	fa   *Factory
+	opt  map[string]interface{}
+	done []bool           // whether each attribute has been processed.
+	seqs map[*int64]int64 // values of sequence groups taken for the instance.
+	// current is the attribute being processed, which is named in the error of a recovered panic.
+	current string
 }
 
 // Instance returns a object to which the generator declared just before is applied
@@ -51,21 +113,88 @@ func (args *argsStruct) Parent() Args {
 	return args.pl.parent
 }
 
-func (args *argsStruct) pipeline(num int) *pipeline {
+// ParentField returns the value of the named attribute of the parent object.
+// Subfactories are applied in the order of generators, so the parent attributes
+// declared before the subfactory attribute are guaranteed to be set.
+// It returns an error if the attribute isn't generated yet.
+func (args *argsStruct) ParentField(name string) (interface{}, error) {
+	parent, ok := args.Parent().(*argsStruct)
+	if !ok {
+		return nil, errors.New("no parent for " + args.fa.modelName())
+	}
+	idx, ok := parent.fa.nameIndexMap[name]
+	if !ok {
+		return nil, errors.New("No such attribute name: " + name)
+	}
+	if !parent.done[idx] {
+		return nil, fmt.Errorf("%v.%v is not generated yet, it should be declared before the sub factory", parent.fa.modelName(), name)
+	}
+	return reflect.Indirect(*parent.rv).Field(idx).Interface(), nil
+}
+
+// ParentSnapshot returns a shallow copy of the parent object at the moment, or nil if there is no parent.
+// Only attributes declared before the sub factory are guaranteed to be generated.
+func (args *argsStruct) ParentSnapshot() interface{} {
+	parent := args.Parent()
+	if parent == nil {
+		return nil
+	}
+	inst := reflect.ValueOf(parent.Instance())
+	cp := reflect.New(reflect.Indirect(inst).Type())
+	cp.Elem().Set(reflect.Indirect(inst))
+	if inst.Kind() == reflect.Ptr {
+		return cp.Interface()
+	}
+	return cp.Elem().Interface()
+}
+
+func (args *argsStruct) pipeline() *pipeline {
 	if args.pl == nil {
-		return newPipeline(num)
+		return newPipeline()
 	}
 	return args.pl
 }
 
+// factory returns the factory which is creating the current object.
+func (args *argsStruct) factory() *Factory {
+	return args.fa
+}
+
+// option returns the value of the option passed on creation.
+func (args *argsStruct) option(key string) (interface{}, bool) {
+	v, ok := args.opt[key]
+	return v, ok
+}
+
+// groupSeq returns the value of the sequence group for the instance,
+// advancing the sequence on first access.
+func (args *argsStruct) groupSeq(seq *int64) int64 {
+	if n, ok := args.seqs[seq]; ok {
+		return n
+	}
+	if args.seqs == nil {
+		args.seqs = make(map[*int64]int64)
+	}
+	n := atomic.AddInt64(args.pipeline().seqs.counter(seq), 1)
+	args.seqs[seq] = n
+	return n
+}
+
 func (args *argsStruct) Context() context.Context {
 	return args.ctx
 }
 
+// UpdateContext replaces the context for the following generators of the current object and its sub objects.
+// It doesn't affect the parent object or the sibling objects.
 func (args *argsStruct) UpdateContext(ctx context.Context) {
 	args.ctx = ctx
 }
 
+// Deadline returns the deadline of the context of creation, so that generators can budget their work.
+func (args *argsStruct) Deadline() (time.Time, bool) {
+	return args.ctx.Deadline()
+}
+
 type Stacks []*int64
 
 func (st *Stacks) Size(idx int) int64 {
@@ -74,6 +203,9 @@ func (st *Stacks) Size(idx int) int64 {
 
 // Set method is not goroutine safe.
 func (st *Stacks) Set(idx, val int) {
+	for len(*st) <= idx {
+		*st = append(*st, nil)
+	}
 	var ini int64 = 0
 	(*st)[idx] = &ini
 	atomic.StoreInt64((*st)[idx], int64(val))
@@ -93,21 +225,82 @@ func (st *Stacks) Next(idx int) bool {
 }
 
 func (st *Stacks) Has(idx int) bool {
-	return (*st)[idx] != nil
+	return idx < len(*st) && (*st)[idx] != nil
 }
 
 type pipeline struct {
 	stacks Stacks
 	parent Args
+	scope  map[string]interface{} // values shared by all objects in a single create call.
+	errs   *[]error               // errors of generators collected by CreateBestEffort.
+	skip   map[int]bool           // field indexes of the root object left zero by CreateSkipping.
+	chain  []*Factory             // factories of the ancestors being built.
+	slots  map[*recursiveAttr]int // slots of Stacks shared by all objects in a single create call.
+	seqs   *seqScope              // counters of the root factory, shared by sub factories.
+}
+
+// newPipeline returns a pipeline of a root object.
+// Stacks are indexed by slots of recursive attributes, and grow only when a slot is used.
+func newPipeline() *pipeline {
+	return &pipeline{scope: make(map[string]interface{}), slots: make(map[*recursiveAttr]int)}
+}
+
+// recursiveAttr identifies a recursive attribute declared on a factory.
+type recursiveAttr struct {
+	name string
 }
 
-func newPipeline(size int) *pipeline {
-	return &pipeline{stacks: make(Stacks, size)}
+// slot returns the slot of Stacks for attr, assigning the next one on its first use in a create call.
+func (pl *pipeline) slot(attr *recursiveAttr) int {
+	if slot, ok := pl.slots[attr]; ok {
+		return slot
+	}
+	slot := len(pl.slots)
+	pl.slots[attr] = slot
+	return slot
+}
+
+// nextRecursive is like Next, but starts the chain over since recursive sub factories terminate by their limits.
+func (pl *pipeline) nextRecursive(args Args) *pipeline {
+	npl := pl.Next(args)
+	npl.chain = nil
+	return npl
+}
+
+// checkCycle returns an error if fa is already in the chain of ancestors
+// since the nearest recursive sub factory.
+func (pl *pipeline) checkCycle(fa *Factory) error {
+	for i, ancestor := range pl.chain {
+		if ancestor != fa {
+			continue
+		}
+		names := make([]string, 0, len(pl.chain)-i+1)
+		for _, f := range pl.chain[i:] {
+			names = append(names, f.modelName())
+		}
+		names = append(names, fa.modelName())
+		return fmt.Errorf("%w: %v", ErrCycleDetected, strings.Join(names, " -> "))
+	}
+	return nil
+}
+
+// recordError keeps err and returns true if errors are collected by CreateBestEffort.
+func (pl *pipeline) recordError(err error) bool {
+	if pl.errs == nil {
+		return false
+	}
+	*pl.errs = append(*pl.errs, err)
+	return true
 }
 
 func (pl *pipeline) Next(args Args) *pipeline {
 	npl := &pipeline{}
 	npl.parent = args
+	npl.scope = pl.scope
+	npl.slots = pl.slots
+	npl.seqs = pl.seqs
+	npl.errs = pl.errs
+	npl.chain = append(append([]*Factory(nil), pl.chain...), args.factory())
 	npl.stacks = make(Stacks, len(pl.stacks))
 	for i, sptr := range pl.stacks {
 		if sptr != nil {
@@ -124,16 +317,39 @@ func NewFactory(model interface{}) *Factory {
 	fa := &Factory{}
 	fa.model = model
 	fa.nameIndexMap = make(map[string]int)
+	fa.applyDefaults()
 
 	fa.init()
 	return fa
 }
 
 type attrGenerator struct {
-	genFunc func(Args) (interface{}, error)
-	key     string
-	value   interface{}
-	isNil   bool
+	genFunc  func(Args) (interface{}, error)
+	key      string
+	value    interface{}
+	isNil    bool
+	deps     []int        // field indexes which should be generated before this attribute.
+	seq      *int64       // counter of sequence generators.
+	priority int          // attributes with higher priority are generated first.
+	skip     bool         // whether the attribute is left untouched by build.
+	unique   *uniqueState // integers handed out by UniqueIntRange.
+}
+
+// defaultValue returns the default value of the attribute, or nil if it has none.
+func (ag *attrGenerator) defaultValue() interface{} {
+	if ag.isNil {
+		return nil
+	}
+	return ag.value
+}
+
+// hasDefault returns whether the attribute has a non-zero default value.
+func (ag *attrGenerator) hasDefault() bool {
+	if ag.isNil {
+		return false
+	}
+	rv := reflect.ValueOf(ag.value)
+	return rv.IsValid() && !rv.IsZero()
 }
 
 func (fa *Factory) init() {
@@ -147,6 +363,10 @@ func (fa *Factory) init() {
 		rv = rv.Elem()
 	}
 
+	if rt.Kind() != reflect.Struct {
+		panic("Model should be a struct, use NewScalarFactory for " + rt.String())
+	}
+
 	fa.numField = rv.NumField()
 
 	for i := 0; i < fa.numField; i++ {
@@ -154,18 +374,38 @@ func (fa *Factory) init() {
 		vf := rv.Field(i)
 		ag := &attrGenerator{}
 
-		if !vf.CanSet() || (tf.Type.Kind() == reflect.Ptr && vf.IsNil()) {
+		if tf.PkgPath != "" || ((tf.Type.Kind() == reflect.Ptr || tf.Type.Kind() == reflect.Interface) && vf.IsNil()) {
 			ag.isNil = true
 		} else {
 			ag.value = vf.Interface()
 		}
 
-		attrName := getAttrName(tf, TagName)
+		// A default declared in the tag is used unless the model has its own value.
+		if def, ok := getAttrOptions(tf, fa.tagName)["default"]; ok && tf.PkgPath == "" && vf.IsZero() {
+			dv, err := convertString(def, tf.Type)
+			if err != nil {
+				panic("Invalid default value for " + tf.Name + ": " + err.Error())
+			}
+			ag.value = dv.Interface()
+			ag.isNil = false
+		}
+
+		if p, ok := getAttrOptions(tf, fa.tagName)["priority"]; ok {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				panic("Invalid priority for " + tf.Name + ": " + err.Error())
+			}
+			ag.priority = n
+		}
+
+		attrName := getAttrName(tf, fa.tagName)
 		ag.key = attrName
 		fa.nameIndexMap[attrName] = i
 		fa.attrGens = append(fa.attrGens, ag)
 	}
 
+	fa.order = fa.priorityOrder()
+	fa.sorted = append([]int(nil), fa.order...)
 	fa.rt = rt
 	fa.rv = &rv
 }
@@ -176,250 +416,1625 @@ func (fa *Factory) modelName() string {
 
 func (fa *Factory) Attr(name string, gen func(Args) (interface{}, error)) *Factory {
 	idx := fa.checkIdx(name)
-	fa.attrGens[idx].genFunc = gen
-	return fa
-}
-
-func (fa *Factory) SeqInt(name string, gen func(int) (interface{}, error)) *Factory {
-	idx := fa.checkIdx(name)
-	var seq int64 = 0
-	fa.attrGens[idx].genFunc = func(args Args) (interface{}, error) {
-		new := atomic.AddInt64(&seq, 1)
-		return gen(int(new))
-	}
+	fa.setGen(idx, gen)
 	return fa
 }
 
-func (fa *Factory) SeqInt64(name string, gen func(int64) (interface{}, error)) *Factory {
+// AttrIf registers a generator which is applied only when cond returns true.
+// Otherwise the attribute keeps its default value.
+func (fa *Factory) AttrIf(name string, cond func(Args) bool, gen func(Args) (interface{}, error)) *Factory {
 	idx := fa.checkIdx(name)
-	var seq int64 = 0
-	fa.attrGens[idx].genFunc = func(args Args) (interface{}, error) {
-		new := atomic.AddInt64(&seq, 1)
-		return gen(new)
-	}
+	ag := fa.attrGens[idx]
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		if !cond(args) {
+			return ag.defaultValue(), nil
+		}
+		return gen(args)
+	})
 	return fa
 }
 
-func (fa *Factory) SeqString(name string, gen func(string) (interface{}, error)) *Factory {
+// AttrScan is like Attr, but if the type of the attribute implements sql.Scanner,
+// the generated value is passed to its Scan method instead of being set directly.
+func (fa *Factory) AttrScan(name string, gen func(Args) (interface{}, error)) *Factory {
 	idx := fa.checkIdx(name)
-	var seq int64 = 0
-	fa.attrGens[idx].genFunc = func(args Args) (interface{}, error) {
-		new := atomic.AddInt64(&seq, 1)
-		return gen(strconv.FormatInt(new, 10))
-	}
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		v, err := gen(args)
+		if err != nil {
+			return nil, err
+		}
+		var ptr reflect.Value
+		if reflect.PtrTo(tp).Implements(scannerType) {
+			ptr = reflect.New(tp)
+		} else if tp.Kind() == reflect.Ptr && tp.Implements(scannerType) {
+			ptr = reflect.New(tp.Elem())
+		} else {
+			return v, nil
+		}
+		if err := ptr.Interface().(sql.Scanner).Scan(v); err != nil {
+			return nil, fmt.Errorf("cannot scan %v: %v", name, err)
+		}
+		if ptr.Type() == tp {
+			return ptr.Interface(), nil
+		}
+		return ptr.Elem().Interface(), nil
+	})
 	return fa
 }
 
-func (fa *Factory) SubFactory(name string, sub *Factory) *Factory {
+// AttrValidate is like Attr, but the generated value is checked by check right after generation.
+// If check returns an error, the creation fails with it.
+func (fa *Factory) AttrValidate(name string, gen func(Args) (interface{}, error), check func(interface{}) error) *Factory {
 	idx := fa.checkIdx(name)
-	fa.attrGens[idx].genFunc = func(args Args) (interface{}, error) {
-		pipeline := args.pipeline(fa.numField)
-		ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		v, err := gen(args)
 		if err != nil {
 			return nil, err
 		}
-		return ret, nil
-	}
+		if err := check(v); err != nil {
+			return nil, fmt.Errorf("%v.%v is invalid: %v", fa.modelName(), name, err)
+		}
+		return v, nil
+	})
 	return fa
 }
 
-func (fa *Factory) SubSliceFactory(name string, sub *Factory, getSize func() int) *Factory {
+// AttrFromContext sets the value stored in the context with key to the attribute.
+// If the context doesn't have the value, the attribute keeps its default value when fallback is true,
+// otherwise creation is failed.
+func (fa *Factory) AttrFromContext(name string, key interface{}, fallback bool) *Factory {
 	idx := fa.checkIdx(name)
+	ag := fa.attrGens[idx]
 	tp := fa.rt.Field(idx).Type
-	fa.attrGens[idx].genFunc = func(args Args) (interface{}, error) {
-		size := getSize()
-		pipeline := args.pipeline(fa.numField)
-		sv := reflect.MakeSlice(tp, size, size)
-		for i := 0; i < size; i++ {
-			ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
-			if err != nil {
-				return nil, err
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		v := args.Context().Value(key)
+		if v == nil {
+			if fallback {
+				return ag.defaultValue(), nil
 			}
-			sv.Index(i).Set(reflect.ValueOf(ret))
+			return nil, fmt.Errorf("context has no value for %v", name)
 		}
-		return sv.Interface(), nil
-	}
+		if vt := reflect.TypeOf(v); !vt.AssignableTo(tp) {
+			return nil, fmt.Errorf("%v in context is not assignable to %v", vt, name)
+		}
+		return v, nil
+	})
 	return fa
 }
 
-func (fa *Factory) SubRecursiveFactory(name string, sub *Factory, getLimit func() int) *Factory {
+// ComputeAttr generates the attribute from the values of other attributes.
+// The attributes in deps are generated before this attribute regardless of the declaration order,
+// and fn receives their values keyed by attribute name.
+func (fa *Factory) ComputeAttr(name string, deps []string, fn func(values map[string]interface{}) (interface{}, error)) *Factory {
 	idx := fa.checkIdx(name)
-	fa.attrGens[idx].genFunc = func(args Args) (interface{}, error) {
-		pl := args.pipeline(fa.numField)
-		if !pl.stacks.Has(idx) {
-			pl.stacks.Set(idx, getLimit())
-		}
-		if pl.stacks.Next(idx) {
-			ret, err := sub.create(args.Context(), nil, pl.Next(args))
-			if err != nil {
-				return nil, err
-			}
-			return ret, nil
-		}
-		return nil, nil
+	depIdxs := make([]int, len(deps))
+	for i, dep := range deps {
+		depIdxs[i] = fa.checkIdx(dep)
 	}
+	fa.dependOn(idx, depIdxs...)
+
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		rv := reflect.Indirect(reflect.ValueOf(args.Instance()))
+		values := make(map[string]interface{}, len(deps))
+		for i, dep := range deps {
+			values[dep] = rv.Field(depIdxs[i]).Interface()
+		}
+		return fn(values)
+	})
 	return fa
 }
 
-func (fa *Factory) SubRecursiveSliceFactory(name string, sub *Factory, getSize, getLimit func() int) *Factory {
+// AttrSum sets the sum of the addend attributes to the attribute.
+// All attributes should be numeric of the same kind, that is signed integers,
+// unsigned integers or floats. The addends are generated before this attribute.
+func (fa *Factory) AttrSum(name string, addends ...string) *Factory {
 	idx := fa.checkIdx(name)
 	tp := fa.rt.Field(idx).Type
-	fa.attrGens[idx].genFunc = func(args Args) (interface{}, error) {
-		pl := args.pipeline(fa.numField)
-		if !pl.stacks.Has(idx) {
-			pl.stacks.Set(idx, getLimit())
+	kind := numericKind(tp)
+	if kind == reflect.Invalid {
+		panic("Attribute should be numeric: " + name)
+	}
+	addendIdxs := make([]int, len(addends))
+	for i, addend := range addends {
+		addendIdxs[i] = fa.checkIdx(addend)
+		if numericKind(fa.rt.Field(addendIdxs[i]).Type) != kind {
+			panic(addend + " is not compatible with " + name)
 		}
-		if pl.stacks.Next(idx) {
-			size := getSize()
-			sv := reflect.MakeSlice(tp, size, size)
-			for i := 0; i < size; i++ {
-				ret, err := sub.create(args.Context(), nil, pl.Next(args))
-				if err != nil {
-					return nil, err
-				}
-				sv.Index(i).Set(reflect.ValueOf(ret))
+	}
+	fa.dependOn(idx, addendIdxs...)
+
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		rv := reflect.Indirect(reflect.ValueOf(args.Instance()))
+		sum := reflect.New(tp).Elem()
+		for _, i := range addendIdxs {
+			v := rv.Field(i)
+			switch kind {
+			case reflect.Int:
+				sum.SetInt(sum.Int() + v.Int())
+			case reflect.Uint:
+				sum.SetUint(sum.Uint() + v.Uint())
+			case reflect.Float64:
+				sum.SetFloat(sum.Float() + v.Float())
 			}
-			return sv.Interface(), nil
 		}
-		return nil, nil
-	}
+		return sum.Interface(), nil
+	})
 	return fa
 }
 
-// OnCreate registers a callback on object creation.
-// If callback function returns error, object creation is failed.
-func (fa *Factory) OnCreate(cb func(Args) error) *Factory {
-	fa.onCreate = cb
+// AttrHash sets the hash of the source attributes to the attribute.
+// The values of the source attributes are formatted by fmt with "%v", separated by newlines,
+// and passed to hashFn. The source attributes are generated before this attribute.
+func (fa *Factory) AttrHash(name string, sourceFields []string, hashFn func([]byte) string) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	if tp.Kind() != reflect.String {
+		panic("Attribute should be string: " + name)
+	}
+	srcIdxs := make([]int, len(sourceFields))
+	for i, src := range sourceFields {
+		srcIdxs[i] = fa.checkIdx(src)
+	}
+	fa.dependOn(idx, srcIdxs...)
+
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		rv := reflect.Indirect(reflect.ValueOf(args.Instance()))
+		var buf bytes.Buffer
+		for _, i := range srcIdxs {
+			fmt.Fprintf(&buf, "%v\n", rv.Field(i).Interface())
+		}
+		return reflect.ValueOf(hashFn(buf.Bytes())).Convert(tp).Interface(), nil
+	})
 	return fa
 }
 
-func (fa *Factory) checkIdx(name string) int {
-	idx, ok := fa.nameIndexMap[name]
-	if !ok {
-		panic("No such attribute name: " + name)
+// numericKind returns reflect.Int, reflect.Uint or reflect.Float64 for signed integers,
+// unsigned integers or floats respectively, otherwise reflect.Invalid.
+func numericKind(tp reflect.Type) reflect.Kind {
+	switch tp.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.Uint
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
 	}
-	return idx
+	return reflect.Invalid
 }
 
-func (fa *Factory) Create() (interface{}, error) {
-	return fa.CreateWithOption(nil)
+func (fa *Factory) SeqInt(name string, gen func(int) (interface{}, error)) *Factory {
+	idx := fa.checkIdx(name)
+	fa.attrGens[idx].seq = new(int64)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		new := args.factory().nextSeq(args, idx)
+		return gen(int(new))
+	})
+	return fa
 }
 
-func (fa *Factory) CreateWithOption(opt map[string]interface{}) (interface{}, error) {
-	return fa.create(context.Background(), opt, nil)
+// SeqIntStep is like SeqInt, but the nth value is start + step*(n-1).
+// step can be negative for descending sequences.
+func (fa *Factory) SeqIntStep(name string, start, step int, gen func(int) (interface{}, error)) *Factory {
+	idx := fa.checkIdx(name)
+	fa.attrGens[idx].seq = new(int64)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		new := args.factory().nextSeq(args, idx)
+		return gen(start + step*int(new-1))
+	})
+	return fa
 }
 
-func (fa *Factory) CreateWithContext(ctx context.Context) (interface{}, error) {
-	return fa.create(ctx, nil, nil)
+func (fa *Factory) SeqInt64(name string, gen func(int64) (interface{}, error)) *Factory {
+	idx := fa.checkIdx(name)
+	fa.attrGens[idx].seq = new(int64)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		new := args.factory().nextSeq(args, idx)
+		return gen(new)
+	})
+	return fa
 }
 
-func (fa *Factory) CreateWithContextAndOption(ctx context.Context, opt map[string]interface{}) (interface{}, error) {
-	return fa.create(ctx, opt, nil)
+func (fa *Factory) SeqString(name string, gen func(string) (interface{}, error)) *Factory {
+	idx := fa.checkIdx(name)
+	fa.attrGens[idx].seq = new(int64)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		new := args.factory().nextSeq(args, idx)
+		return gen(strconv.FormatInt(new, 10))
+	})
+	return fa
 }
 
-func (fa *Factory) MustCreate() interface{} {
-	return fa.MustCreateWithOption(nil)
+// SeqGroup generates values of several attributes from a single shared sequence.
+// All attributes in the group receive the same counter per object, and gen is
+// called with the attribute name to format the value for each field.
+func (fa *Factory) SeqGroup(names []string, gen func(n int64, name string) (interface{}, error)) *Factory {
+	seq := new(int64)
+	for _, name := range names {
+		name := name
+		idx := fa.checkIdx(name)
+		fa.attrGens[idx].seq = seq
+		fa.setGen(idx, func(args Args) (interface{}, error) {
+			return gen(args.groupSeq(args.factory().attrGens[idx].seq), name)
+		})
+	}
+	return fa
 }
 
-func (fa *Factory) MustCreateWithOption(opt map[string]interface{}) interface{} {
-	return fa.MustCreateWithContextAndOption(context.Background(), opt)
+// nextSeq advances the sequence of the attribute and returns the new value.
+func (fa *Factory) nextSeq(args Args, idx int) int64 {
+	return atomic.AddInt64(args.pipeline().seqs.counter(fa.attrGens[idx].seq), 1)
 }
 
-func (fa *Factory) MustCreateWithContextAndOption(ctx context.Context, opt map[string]interface{}) interface{} {
-	inst, err := fa.CreateWithContextAndOption(ctx, opt)
-	if err != nil {
-		panic(err)
+// SequenceState returns the current counters of sequence attributes keyed by attribute name.
+func (fa *Factory) SequenceState() map[string]int64 {
+	state := make(map[string]int64)
+	for _, ag := range fa.attrGens {
+		if ag.seq != nil {
+			state[ag.key] = atomic.LoadInt64(fa.seqs.counter(ag.seq))
+		}
 	}
-	return inst
+	return state
 }
 
-/*
-Bind values of a new objects to a pointer to struct.
+// RestoreSequenceState sets counters of sequence attributes.
+// The next value of each sequence is the given counter + 1.
+func (fa *Factory) RestoreSequenceState(state map[string]int64) {
+	for name, n := range state {
+		ag := fa.attrGens[fa.checkIdx(name)]
+		if ag.seq == nil {
+			panic("Attribute is not a sequence: " + name)
+		}
+		atomic.StoreInt64(fa.seqs.counter(ag.seq), n)
+	}
+}
 
-ptr: a pointer to struct
-*/
-func (fa *Factory) Construct(ptr interface{}) error {
-	return fa.ConstructWithOption(ptr, nil)
+// ResetSequences resets all sequences to start from the first value again.
+func (fa *Factory) ResetSequences() {
+	for _, ag := range fa.attrGens {
+		if ag.seq != nil {
+			atomic.StoreInt64(fa.seqs.counter(ag.seq), 0)
+		}
+	}
 }
 
-/*
-Bind values of a new objects to a pointer to struct with option.
+// withFreshSequences returns a clone of the factory whose sequences start from the first value
+// and unique ranges hand out all integers again, independently of the original factory.
+// This also applies to sub factories and AutoFill while they create objects for the clone.
+func (fa *Factory) withFreshSequences() *Factory {
+	nfa := fa.Clone()
+	nfa.seqs = newSeqScope()
+	return nfa
+}
 
-ptr: a pointer to struct
-opt: attibute values
-*/
-func (fa *Factory) ConstructWithOption(ptr interface{}, opt map[string]interface{}) error {
-	return fa.ConstructWithContextAndOption(context.Background(), ptr, opt)
+// seqScope holds counters and unique ranges used instead of the declared ones,
+// keyed by the declared ones. A nil scope uses the declared ones.
+type seqScope struct {
+	mu       sync.Mutex
+	counters map[*int64]*int64
+	uniques  map[*uniqueState]*uniqueState
 }
 
-/*
-Bind values of a new objects to a pointer to struct with context and option.
+func newSeqScope() *seqScope {
+	return &seqScope{counters: make(map[*int64]*int64), uniques: make(map[*uniqueState]*uniqueState)}
+}
 
-ctx: context object
-ptr: a pointer to struct
-opt: attibute values
-*/
-func (fa *Factory) ConstructWithContextAndOption(ctx context.Context, ptr interface{}, opt map[string]interface{}) error {
-	pt := reflect.TypeOf(ptr)
-	if pt.Kind() != reflect.Ptr {
-		return errors.New("ptr should be pointer type.")
+// counter returns the counter used for seq, which starts from zero in the scope.
+func (sc *seqScope) counter(seq *int64) *int64 {
+	if sc == nil {
+		return seq
 	}
-	pt = pt.Elem()
-	if pt.Name() != fa.modelName() {
-		return errors.New("ptr type should be " + fa.modelName())
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	c, ok := sc.counters[seq]
+	if !ok {
+		c = new(int64)
+		sc.counters[seq] = c
 	}
-
-	inst := reflect.ValueOf(ptr).Elem()
-	_, err := fa.build(ctx, &inst, pt, opt, nil)
-	return err
+	return c
 }
 
-func (fa *Factory) build(ctx context.Context, inst *reflect.Value, tp reflect.Type, opt map[string]interface{}, pl *pipeline) (interface{}, error) {
-	args := &argsStruct{}
-	args.pl = pl
-	args.ctx = ctx
-	if fa.isPtr {
-		addr := (*inst).Addr()
-		args.rv = &addr
-	} else {
-		args.rv = inst
+// unique returns the state used for us, which has handed out no integers in the scope.
+func (sc *seqScope) unique(us *uniqueState) *uniqueState {
+	if sc == nil {
+		return us
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	u, ok := sc.uniques[us]
+	if !ok {
+		u = &uniqueState{}
+		sc.uniques[us] = u
 	}
+	return u
+}
 
-	for i := 0; i < fa.numField; i++ {
-		if v, ok := opt[fa.attrGens[i].key]; ok {
-			inst.Field(i).Set(reflect.ValueOf(v))
-		} else {
-			ag := fa.attrGens[i]
-			if ag.genFunc == nil {
-				if !ag.isNil {
-					inst.Field(i).Set(reflect.ValueOf(ag.value))
-				}
-			} else {
-				v, err := ag.genFunc(args)
-				if err != nil {
-					return nil, err
-				}
-				if v != nil {
-					inst.Field(i).Set(reflect.ValueOf(v))
-				}
-			}
+// reset makes all counters and unique ranges in the scope start over.
+func (sc *seqScope) reset() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.counters = make(map[*int64]*int64)
+	sc.uniques = make(map[*uniqueState]*uniqueState)
+}
+
+// ScopedSeq generates a sequential value shared by all objects in a single create call.
+// The sequence advances once per root object, and sub factories declaring
+// ScopedSeq with the same name receive the same value.
+func (fa *Factory) ScopedSeq(name string, gen func(int64) (interface{}, error)) *Factory {
+	idx := fa.checkIdx(name)
+	fa.attrGens[idx].seq = new(int64)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		pl := args.pipeline()
+		if v, ok := pl.scope[name]; ok {
+			return v, nil
 		}
-	}
+		new := args.factory().nextSeq(args, idx)
+		v, err := gen(new)
+		if err != nil {
+			return nil, err
+		}
+		pl.scope[name] = v
+		return v, nil
+	})
+	return fa
+}
 
-	for k, v := range opt {
-		setValueWithAttrPath(inst, tp, k, v)
+// SeqSlug generates sequential slugs like "prefix-1", "prefix-2".
+// If width is given, the number is padded with zeros to the width like "prefix-001".
+func (fa *Factory) SeqSlug(name, prefix string, width ...int) *Factory {
+	format := prefix + "-%d"
+	if len(width) > 0 {
+		format = prefix + "-%0" + strconv.Itoa(width[0]) + "d"
 	}
+	idx := fa.checkIdx(name)
+	fa.attrGens[idx].seq = new(int64)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		new := args.factory().nextSeq(args, idx)
+		return fmt.Sprintf(format, new), nil
+	})
+	return fa
+}
 
-	if fa.onCreate != nil {
-		if err := fa.onCreate(args); err != nil {
+func (fa *Factory) SubFactory(name string, sub *Factory) *Factory {
+	idx := fa.checkIdx(name)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		pipeline := args.pipeline()
+		ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
+		if err != nil {
 			return nil, err
 		}
-	}
+		return ret, nil
+	})
+	return fa
+}
 
-	if fa.isPtr {
-		return (*inst).Addr().Interface(), nil
-	}
-	return inst.Interface(), nil
+// EmbedFactory applies generators of base to the embedded struct whose type name is embeddedTypeName.
+// The embedded field can be either a pointer or a value regardless of the model of base.
+func (fa *Factory) EmbedFactory(embeddedTypeName string, base *Factory) *Factory {
+	idx := -1
+	for i := 0; i < fa.numField; i++ {
+		sf := fa.rt.Field(i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && ft.Name() == embeddedTypeName {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		panic("No such embedded type: " + embeddedTypeName)
+	}
+	tp := fa.rt.Field(idx).Type
+	if base.rt != tp && (tp.Kind() != reflect.Ptr || base.rt != tp.Elem()) {
+		panic(base.rt.String() + " is not assignable to " + embeddedTypeName)
+	}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		pipeline := args.pipeline()
+		ret, err := base.create(args.Context(), nil, pipeline.Next(args))
+		if err != nil {
+			return nil, err
+		}
+		return adaptValue(ret, tp).Interface(), nil
+	})
+	return fa
+}
+
+// SubFactoryWithOption is like SubFactory, but the sub object is created with option.
+func (fa *Factory) SubFactoryWithOption(name string, sub *Factory, opt map[string]interface{}) *Factory {
+	idx := fa.checkIdx(name)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		pipeline := args.pipeline()
+		return sub.create(args.Context(), opt, pipeline.Next(args))
+	})
+	return fa
+}
+
+// SubFactoryIf creates a sub object only when cond returns true, otherwise the attribute is left zero.
+// cond can read attributes of the instance declared before the sub factory.
+func (fa *Factory) SubFactoryIf(name string, sub *Factory, cond func(Args) bool) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		if !cond(args) {
+			return nil, nil
+		}
+		pipeline := args.pipeline()
+		ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
+		if err != nil {
+			return nil, err
+		}
+		return adaptValue(ret, tp).Interface(), nil
+	})
+	return fa
+}
+
+// SubFactoryOptional creates a sub object with probability prob, otherwise the attribute stays nil.
+// The attribute should be a pointer.
+func (fa *Factory) SubFactoryOptional(name string, sub *Factory, prob float64) *Factory {
+	idx := fa.checkIdx(name)
+	if fa.rt.Field(idx).Type.Kind() != reflect.Ptr {
+		panic("Attribute should be a pointer: " + name)
+	}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		if args.factory().random().Float64() >= prob {
+			return nil, nil
+		}
+		pipeline := args.pipeline()
+		return sub.create(args.Context(), nil, pipeline.Next(args))
+	})
+	return fa
+}
+
+// SharedPool sets one of poolSize objects shared among created objects, chosen randomly.
+// The objects in the pool are created by sub on first use and reused afterwards.
+// They are created as sub objects of the object using the pool first, with its context.
+// The attribute should be a pointer or an interface to which the model of sub is assignable.
+func (fa *Factory) SharedPool(name string, poolSize int, sub *Factory) *Factory {
+	if poolSize <= 0 {
+		panic("Pool size should be positive: " + name)
+	}
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	if tp.Kind() != reflect.Ptr && tp.Kind() != reflect.Interface {
+		panic("Attribute should be a pointer or an interface: " + name)
+	}
+	if mt := reflect.TypeOf(sub.model); !mt.AssignableTo(tp) {
+		panic(mt.String() + " is not assignable to " + name)
+	}
+	var mu sync.Mutex
+	var pool []interface{}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		mu.Lock()
+		size := len(pool)
+		mu.Unlock()
+
+		// The lock isn't held while creating objects, which can use the pool again.
+		var created []interface{}
+		for i := size; i < poolSize; i++ {
+			ret, err := sub.create(args.Context(), nil, args.pipeline().Next(args))
+			if err != nil {
+				return nil, err
+			}
+			created = append(created, ret)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ret := range created {
+			if len(pool) < poolSize {
+				pool = append(pool, ret)
+			}
+		}
+		return pool[args.factory().random().Intn(poolSize)], nil
+	})
+	return fa
+}
+
+// SubFactoryInterface creates a sub object by the factory in impls chosen by pick, and sets it to the interface attribute.
+// The models of all factories in impls should implement the interface.
+func (fa *Factory) SubFactoryInterface(name string, impls map[string]*Factory, pick func(Args) string) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	if tp.Kind() != reflect.Interface {
+		panic("Attribute should be an interface: " + name)
+	}
+	for _, impl := range impls {
+		if mt := reflect.TypeOf(impl.model); !mt.Implements(tp) {
+			panic(mt.String() + " is not assignable to " + name)
+		}
+	}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		key := pick(args)
+		impl, ok := impls[key]
+		if !ok {
+			return nil, fmt.Errorf("no such implementation for %v: %v", name, key)
+		}
+		pipeline := args.pipeline()
+		return impl.create(args.Context(), nil, pipeline.Next(args))
+	})
+	return fa
+}
+
+// WeightedFactory is a factory with a weight used by SubFactoryWeighted.
+type WeightedFactory struct {
+	Factory *Factory
+	Weight  int
+}
+
+// SubFactoryWeighted creates a sub object by a factory chosen randomly according to the weights.
+func (fa *Factory) SubFactoryWeighted(name string, choices []WeightedFactory) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	total := 0
+	for _, choice := range choices {
+		if choice.Weight < 0 {
+			panic("Weight should not be negative: " + name)
+		}
+		if mt := reflect.TypeOf(choice.Factory.model); !mt.AssignableTo(tp) {
+			panic(mt.String() + " is not assignable to " + name)
+		}
+		total += choice.Weight
+	}
+	if total <= 0 {
+		panic("Total weight should be positive: " + name)
+	}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		n := args.factory().random().Intn(total)
+		for _, choice := range choices {
+			if n < choice.Weight {
+				pipeline := args.pipeline()
+				return choice.Factory.create(args.Context(), nil, pipeline.Next(args))
+			}
+			n -= choice.Weight
+		}
+		return nil, nil
+	})
+	return fa
+}
+
+func (fa *Factory) SubSliceFactory(name string, sub *Factory, getSize func() int) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		size, err := sliceSize(args, name, getSize)
+		if err != nil {
+			return nil, err
+		}
+		pipeline := args.pipeline()
+		sv := reflect.MakeSlice(tp, size, size)
+		for i := 0; i < size; i++ {
+			ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
+			if err != nil {
+				return nil, err
+			}
+			sv.Index(i).Set(reflect.ValueOf(ret))
+		}
+		return sv.Interface(), nil
+	})
+	return fa
+}
+
+// SubSliceFactoryFromField is like SubSliceFactory, but the size of the slice is the value of countField.
+// countField should be an integer attribute, and it is generated before this attribute.
+func (fa *Factory) SubSliceFactoryFromField(name string, sub *Factory, countField string) *Factory {
+	idx := fa.checkIdx(name)
+	countIdx := fa.checkIdx(countField)
+	switch fa.rt.Field(countIdx).Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic("Count attribute should be an integer: " + countField)
+	}
+	fa.dependOn(idx, countIdx)
+
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		cv := reflect.Indirect(reflect.ValueOf(args.Instance())).Field(countIdx)
+		var size int
+		if cv.Kind() >= reflect.Uint && cv.Kind() <= reflect.Uint64 {
+			size = int(cv.Uint())
+		} else {
+			size = int(cv.Int())
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("count of %v should not be negative: %v", name, size)
+		}
+		pipeline := args.pipeline()
+		sv := reflect.MakeSlice(tp, size, size)
+		for i := 0; i < size; i++ {
+			ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
+			if err != nil {
+				return nil, err
+			}
+			sv.Index(i).Set(reflect.ValueOf(ret))
+		}
+		return sv.Interface(), nil
+	})
+	return fa
+}
+
+// SubSliceFactoryNumbered is like SubSliceFactory, but numberField of the elements is set to 1..N
+// within each slice, so that the elements of each parent are numbered from 1.
+func (fa *Factory) SubSliceFactoryNumbered(name string, sub *Factory, getSize func() int, numberField string) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	nt := sub.rt.Field(sub.checkIdx(numberField)).Type
+	if numericKind(nt) == reflect.Invalid {
+		panic("Attribute should be numeric: " + numberField)
+	}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		size, err := sliceSize(args, name, getSize)
+		if err != nil {
+			return nil, err
+		}
+		pipeline := args.pipeline()
+		sv := reflect.MakeSlice(tp, size, size)
+		for i := 0; i < size; i++ {
+			opt := map[string]interface{}{numberField: reflect.ValueOf(i + 1).Convert(nt).Interface()}
+			ret, err := sub.create(args.Context(), opt, pipeline.Next(args))
+			if err != nil {
+				return nil, err
+			}
+			sv.Index(i).Set(reflect.ValueOf(ret))
+		}
+		return sv.Interface(), nil
+	})
+	return fa
+}
+
+// SubSliceFactoryWeightedSize is like SubSliceFactory, but the size of the slice is chosen
+// randomly according to sizeWeights, which maps each size to its weight.
+func (fa *Factory) SubSliceFactoryWeightedSize(name string, sub *Factory, sizeWeights map[int]int) *Factory {
+	sizes := make([]int, 0, len(sizeWeights))
+	total := 0
+	for size, weight := range sizeWeights {
+		if size < 0 || weight < 0 {
+			panic("Size and weight should not be negative: " + name)
+		}
+		sizes = append(sizes, size)
+		total += weight
+	}
+	if total <= 0 {
+		panic("Total weight should be positive: " + name)
+	}
+	// sizes are sorted so that a seeded factory chooses the same sizes.
+	sort.Ints(sizes)
+	return fa.SubSliceFactory(name, sub, func() int {
+		n := fa.random().Intn(total)
+		for _, size := range sizes {
+			if n < sizeWeights[size] {
+				return size
+			}
+			n -= sizeWeights[size]
+		}
+		return 0
+	})
+}
+
+// SubSliceFactoryWithSpecial is like SubSliceFactory, but the first element is created with firstOpt
+// and the last element is created with lastOpt. If the slice has only one element, both are applied
+// and lastOpt takes precedence.
+func (fa *Factory) SubSliceFactoryWithSpecial(name string, sub *Factory, getSize func() int, firstOpt, lastOpt map[string]interface{}) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		size, err := sliceSize(args, name, getSize)
+		if err != nil {
+			return nil, err
+		}
+		pipeline := args.pipeline()
+		sv := reflect.MakeSlice(tp, size, size)
+		for i := 0; i < size; i++ {
+			var opt map[string]interface{}
+			switch {
+			case size == 1:
+				opt = make(map[string]interface{}, len(firstOpt)+len(lastOpt))
+				for k, v := range firstOpt {
+					opt[k] = v
+				}
+				for k, v := range lastOpt {
+					opt[k] = v
+				}
+			case i == 0:
+				opt = firstOpt
+			case i == size-1:
+				opt = lastOpt
+			}
+			ret, err := sub.create(args.Context(), opt, pipeline.Next(args))
+			if err != nil {
+				return nil, err
+			}
+			sv.Index(i).Set(reflect.ValueOf(ret))
+		}
+		return sv.Interface(), nil
+	})
+	return fa
+}
+
+// SubSliceFactoryCap is like SubSliceFactory, but the capacity of the slice is given by getCap.
+func (fa *Factory) SubSliceFactoryCap(name string, sub *Factory, getSize, getCap func() int) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		size, err := sliceSize(args, name, getSize)
+		if err != nil {
+			return nil, err
+		}
+		capacity := getCap()
+		if capacity < size {
+			return nil, fmt.Errorf("capacity %v is less than length %v for %v", capacity, size, name)
+		}
+		pipeline := args.pipeline()
+		sv := reflect.MakeSlice(tp, size, capacity)
+		for i := 0; i < size; i++ {
+			ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
+			if err != nil {
+				return nil, err
+			}
+			sv.Index(i).Set(reflect.ValueOf(ret))
+		}
+		return sv.Interface(), nil
+	})
+	return fa
+}
+
+// SubSliceFactoryUnion builds a slice whose elements are created by several factories.
+// pick receives the element index and returns the index of the factory in subs to use.
+// Each created object must be assignable to the element type of the slice.
+func (fa *Factory) SubSliceFactoryUnion(name string, subs []*Factory, getSize func() int, pick func(i int) int) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		size, err := sliceSize(args, name, getSize)
+		if err != nil {
+			return nil, err
+		}
+		pipeline := args.pipeline()
+		sv := reflect.MakeSlice(tp, size, size)
+		for i := 0; i < size; i++ {
+			n := pick(i)
+			if n < 0 || n >= len(subs) {
+				return nil, fmt.Errorf("factory index %v is out of range for %v", n, name)
+			}
+			ret, err := subs[n].create(args.Context(), nil, pipeline.Next(args))
+			if err != nil {
+				return nil, err
+			}
+			rv := reflect.ValueOf(ret)
+			if !rv.Type().AssignableTo(tp.Elem()) {
+				return nil, fmt.Errorf("%v is not assignable to %v", rv.Type(), tp.Elem())
+			}
+			sv.Index(i).Set(rv)
+		}
+		return sv.Interface(), nil
+	})
+	return fa
+}
+
+// NestedSliceFactory generates a slice of slices like [][]int with getRows rows and getCols columns.
+// inner generates the element at row i and column j.
+func (fa *Factory) NestedSliceFactory(name string, inner func(args Args, i, j int) (interface{}, error), getRows, getCols func() int) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	if tp.Kind() != reflect.Slice || tp.Elem().Kind() != reflect.Slice {
+		panic("Attribute should be a slice of slices: " + name)
+	}
+	rowType, elemType := tp.Elem(), tp.Elem().Elem()
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		rows, cols := getRows(), getCols()
+		sv := reflect.MakeSlice(tp, rows, rows)
+		for i := 0; i < rows; i++ {
+			row := reflect.MakeSlice(rowType, cols, cols)
+			for j := 0; j < cols; j++ {
+				v, err := inner(args, i, j)
+				if err != nil {
+					return nil, err
+				}
+				if v == nil {
+					continue
+				}
+				ev := adaptValue(v, elemType)
+				if !ev.Type().AssignableTo(elemType) {
+					return nil, fmt.Errorf("%v is not assignable to an element of %v", ev.Type(), name)
+				}
+				row.Index(j).Set(ev)
+			}
+			sv.Index(i).Set(row)
+		}
+		return sv.Interface(), nil
+	})
+	return fa
+}
+
+// sliceSize returns the count given by "<name>.#" option, or the result of getSize.
+func sliceSize(args Args, name string, getSize func() int) (int, error) {
+	v, ok := args.option(name + ".#")
+	if !ok {
+		return getSize(), nil
+	}
+	var n int
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = int(rv.Uint())
+	default:
+		return 0, fmt.Errorf("count of %v should be an integer, not %T", name, v)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("count of %v should not be negative: %v", name, v)
+	}
+	return n, nil
+}
+
+func (fa *Factory) SubRecursiveFactory(name string, sub *Factory, getLimit func() int) *Factory {
+	idx := fa.checkIdx(name)
+	attr := &recursiveAttr{name: name}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		pl := args.pipeline()
+		slot := pl.slot(attr)
+		if !pl.stacks.Has(slot) {
+			pl.stacks.Set(slot, getLimit())
+		}
+		if pl.stacks.Next(slot) {
+			ret, err := sub.create(args.Context(), nil, pl.nextRecursive(args))
+			if err != nil {
+				return nil, err
+			}
+			return ret, nil
+		}
+		args.factory().reachRecursionLimit(name)
+		return nil, nil
+	})
+	return fa
+}
+
+// SubRecursiveSliceFactory creates a slice of sub objects recursively until the depth reaches the limit.
+// The element type can be either a pointer or a value regardless of the model of sub factory.
+func (fa *Factory) SubRecursiveSliceFactory(name string, sub *Factory, getSize, getLimit func() int) *Factory {
+	idx := fa.checkIdx(name)
+	attr := &recursiveAttr{name: name}
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		pl := args.pipeline()
+		slot := pl.slot(attr)
+		if !pl.stacks.Has(slot) {
+			pl.stacks.Set(slot, getLimit())
+		}
+		if pl.stacks.Next(slot) {
+			size := getSize()
+			sv := reflect.MakeSlice(tp, size, size)
+			for i := 0; i < size; i++ {
+				ret, err := sub.create(args.Context(), nil, pl.nextRecursive(args))
+				if err != nil {
+					return nil, err
+				}
+				sv.Index(i).Set(adaptValue(ret, tp.Elem()))
+			}
+			return sv.Interface(), nil
+		}
+		args.factory().reachRecursionLimit(name)
+		return nil, nil
+	})
+	return fa
+}
+
+// SetOrder overrides the order in which generators are applied.
+// Attributes not listed are applied afterward in the default order.
+func (fa *Factory) SetOrder(names ...string) *Factory {
+	listed := make(map[int]bool)
+	order := make([]int, 0, fa.numField)
+	for _, name := range names {
+		idx := fa.checkIdx(name)
+		if !listed[idx] {
+			listed[idx] = true
+			order = append(order, idx)
+		}
+	}
+	for _, i := range fa.priorityOrder() {
+		if !listed[i] {
+			order = append(order, i)
+		}
+	}
+	fa.order = order
+	fa.resolveOrder()
+	return fa
+}
+
+// priorityOrder returns field indexes sorted by the priority in the tag like `factory:"name;priority=10"`.
+// Attributes with higher priority come first, and ties keep declaration order.
+func (fa *Factory) priorityOrder() []int {
+	order := make([]int, len(fa.attrGens))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return fa.attrGens[order[a]].priority > fa.attrGens[order[b]].priority
+	})
+	return order
+}
+
+// resolveOrder sorts attributes so that each attribute follows its dependencies,
+// keeping the order given by SetOrder as much as possible.
+func (fa *Factory) resolveOrder() {
+	placed := make([]bool, fa.numField)
+	sorted := make([]int, 0, len(fa.order))
+	for len(sorted) < len(fa.order) {
+		next := -1
+		for _, i := range fa.order {
+			if !placed[i] && fa.depsPlaced(i, placed) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			panic("Circular dependency among attributes of " + fa.modelName())
+		}
+		placed[next] = true
+		sorted = append(sorted, next)
+	}
+	fa.sorted = sorted
+}
+
+// dependOn makes the attribute generated after the dependencies.
+func (fa *Factory) dependOn(idx int, deps ...int) {
+	ag := fa.attrGens[idx]
+	prevDeps := ag.deps
+	ag.deps = deps
+	defer func() {
+		if r := recover(); r != nil {
+			ag.deps = prevDeps
+			panic(r)
+		}
+	}()
+	fa.resolveOrder()
+}
+
+func (fa *Factory) depsPlaced(idx int, placed []bool) bool {
+	for _, dep := range fa.attrGens[idx].deps {
+		if !placed[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// WithPanicRecovery makes a panic in callbacks returned as an error with its stack trace.
+// By default, a panic in callbacks is returned as an error without the stack trace.
+func (fa *Factory) WithPanicRecovery() *Factory {
+	fa.recoverHooks = true
+	return fa
+}
+
+// callHook invokes a callback, recovering a panic if WithPanicRecovery is enabled.
+func (fa *Factory) callHook(cb func() error) (err error) {
+	if fa.recoverHooks {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic in callback of %v: %v\n%s", fa.modelName(), r, debug.Stack())
+			}
+		}()
+	}
+	return cb()
+}
+
+// OnFirstCreate registers a callback invoked only once before the first object is created.
+// If callback function returns error, the first object creation is failed.
+func (fa *Factory) OnFirstCreate(cb func() error) *Factory {
+	fa.firstCreate = &onceHook{fn: cb}
+	return fa
+}
+
+// BeforeCreate registers a callback invoked before generators are applied.
+// A context updated in the callback is visible to all generators and sub objects.
+// If callback function returns error, object creation is failed.
+func (fa *Factory) BeforeCreate(cb func(Args) error) *Factory {
+	fa.beforeCreate = cb
+	return fa
+}
+
+// OnCreate registers a callback on object creation.
+// If callback function returns error, object creation is failed.
+func (fa *Factory) OnCreate(cb func(Args) error) *Factory {
+	fa.onCreate = cb
+	return fa
+}
+
+// DefaultGen registers a generator for attributes which have neither a generator,
+// a default value nor an option. If it returns nil, the attribute is left as zero value.
+func (fa *Factory) DefaultGen(fn func(field reflect.StructField, args Args) (interface{}, error)) *Factory {
+	fa.defaultGen = fn
+	return fa
+}
+
+// Require makes creation fail if any of the attributes is zero value after build.
+func (fa *Factory) Require(names ...string) *Factory {
+	for _, name := range names {
+		fa.required = append(fa.required, fa.checkIdx(name))
+	}
+	return fa
+}
+
+// WithValidator registers a validator of created objects.
+// If it returns error, object creation is failed.
+func (fa *Factory) WithValidator(fn func(interface{}) error) *Factory {
+	fa.validator = fn
+	return fa
+}
+
+// CreateInvalid creates an object whose attributes in fieldErrors are generated by
+// the given generators, which are expected to produce invalid values. The rest of it stays valid.
+// The object is returned with the failures of the validator registered by WithValidator keyed by
+// the attributes in fieldErrors. Each failure is given by validating the object with only that attribute invalid.
+// It returns an error if the object cannot be created, or the validator accepts any of the invalid values.
+func (fa *Factory) CreateInvalid(fieldErrors map[string]func(Args) (interface{}, error)) (interface{}, map[string]error, error) {
+	if fa.validator == nil {
+		return nil, nil, errors.New("no validator is registered by WithValidator")
+	}
+	opt := make(map[string]interface{}, len(fieldErrors))
+	valid := make(map[int]interface{}, len(fieldErrors))
+	for name, gen := range fieldErrors {
+		idx := fa.checkIdx(name)
+		gen := gen
+		opt[name] = func(args Args) (interface{}, error) {
+			v, err := args.factory().validValue(args, idx)
+			if err != nil {
+				return nil, err
+			}
+			valid[idx] = v
+			return gen(args)
+		}
+	}
+
+	failures := make(map[string]error, len(fieldErrors))
+	nfa := fa.Clone()
+	nfa.validator = func(inst interface{}) error {
+		rv := reflect.Indirect(reflect.ValueOf(inst))
+		for idx := range valid {
+			cp := reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			for other, v := range valid {
+				if other == idx {
+					continue
+				}
+				if v == nil {
+					cp.Field(other).Set(reflect.Zero(cp.Field(other).Type()))
+				} else {
+					cp.Field(other).Set(reflect.ValueOf(v))
+				}
+			}
+			var err error
+			if fa.isPtr {
+				err = fa.validator(cp.Addr().Interface())
+			} else {
+				err = fa.validator(cp.Interface())
+			}
+			name := fa.attrGens[idx].key
+			if err == nil {
+				return fmt.Errorf("validator accepts the invalid value of %v", name)
+			}
+			failures[name] = err
+		}
+		return nil
+	}
+	inst, err := nfa.create(context.Background(), opt, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inst, failures, nil
+}
+
+// validValue returns the value which the attribute has without an option, or nil to leave it as it is.
+func (fa *Factory) validValue(args Args, idx int) (interface{}, error) {
+	ag := fa.attrGens[idx]
+	if ag.genFunc != nil {
+		return ag.genFunc(args)
+	}
+	if sf := fa.rt.Field(idx); fa.defaultGen != nil && !ag.hasDefault() && sf.PkgPath == "" {
+		return fa.defaultGen(sf, args)
+	}
+	if !ag.isNil {
+		return ag.value, nil
+	}
+	return nil, nil
+}
+
+// Abstract marks the factory as a base factory which cannot be created directly.
+// Use Clone to get a factory which can create objects.
+func (fa *Factory) Abstract() *Factory {
+	fa.abstract = true
+	return fa
+}
+
+// Clone returns a copy of the factory, which isn't abstract.
+// Generators are shared with the original factory, so are states of sequences.
+func (fa *Factory) Clone() *Factory {
+	nfa := *fa
+	nfa.abstract = false
+	nfa.attrGens = make([]*attrGenerator, len(fa.attrGens))
+	for i, ag := range fa.attrGens {
+		nag := *ag
+		nfa.attrGens[i] = &nag
+	}
+	nfa.order = append([]int(nil), fa.order...)
+	nfa.sorted = append([]int(nil), fa.sorted...)
+	nfa.required = append([]int(nil), fa.required...)
+	nfa.traitHooks = append([]func(Args) error(nil), fa.traitHooks...)
+	nfa.typeTransforms = append([]typeTransform(nil), fa.typeTransforms...)
+	if fa.metrics != nil {
+		nfa.metrics = newMetricsRecorder()
+	}
+	if fa.traits != nil {
+		nfa.traits = make(map[string]*trait, len(fa.traits))
+		for k, tr := range fa.traits {
+			nfa.traits[k] = tr
+		}
+	}
+	if fa.jsonFuncs != nil {
+		nfa.jsonFuncs = make(map[string]func(interface{}) (json.RawMessage, error), len(fa.jsonFuncs))
+		for k, fn := range fa.jsonFuncs {
+			nfa.jsonFuncs[k] = fn
+		}
+	}
+	return &nfa
+}
+
+// WithTypeTransform registers fn to transform the value of every attribute of type t after it is set.
+// Transforms are applied in the order they are registered.
+func (fa *Factory) WithTypeTransform(t reflect.Type, fn func(interface{}) interface{}) *Factory {
+	fa.typeTransforms = append(fa.typeTransforms, typeTransform{tp: t, fn: fn})
+	return fa
+}
+
+// WithStrictWiring makes the factory panic when a generator is set to
+// an attribute which already has one. Traits can override generators regardless.
+func (fa *Factory) WithStrictWiring() *Factory {
+	fa.strictWiring = true
+	return fa
+}
+
+// setGen sets the generator of the attribute.
+func (fa *Factory) setGen(idx int, gen func(Args) (interface{}, error)) {
+	ag := fa.attrGens[idx]
+	if fa.strictWiring && ag.genFunc != nil {
+		panic("Attribute already has a generator: " + ag.key)
+	}
+	ag.genFunc = gen
+}
+
+func (fa *Factory) checkIdx(name string) int {
+	idx, ok := fa.nameIndexMap[name]
+	if !ok {
+		panic("No such attribute name: " + name)
+	}
+	return idx
+}
+
+type overridesKey struct{}
+
+// WithOverrides returns a context carrying opt, which is applied to every root object created with the context.
+// An option passed explicitly takes precedence over the one carried by the context, and overrides of
+// an outer context are taken over by WithOverrides unless the same keys are given again.
+// Sub objects are not affected.
+func WithOverrides(ctx context.Context, opt map[string]interface{}) context.Context {
+	merged := make(map[string]interface{})
+	if outer, ok := ctx.Value(overridesKey{}).(map[string]interface{}); ok {
+		for k, v := range outer {
+			merged[k] = v
+		}
+	}
+	for k, v := range opt {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, overridesKey{}, merged)
+}
+
+// mergeOverrides returns opt merged with the overrides carried by ctx.
+func mergeOverrides(ctx context.Context, opt map[string]interface{}) map[string]interface{} {
+	overrides, ok := ctx.Value(overridesKey{}).(map[string]interface{})
+	if !ok {
+		return opt
+	}
+	merged := make(map[string]interface{}, len(overrides)+len(opt))
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	for k, v := range opt {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (fa *Factory) Create() (interface{}, error) {
+	return fa.CreateWithOption(nil)
+}
+
+func (fa *Factory) CreateWithOption(opt map[string]interface{}) (interface{}, error) {
+	return fa.create(context.Background(), opt, nil)
+}
+
+func (fa *Factory) CreateWithContext(ctx context.Context) (interface{}, error) {
+	return fa.create(ctx, nil, nil)
+}
+
+func (fa *Factory) CreateWithContextAndOption(ctx context.Context, opt map[string]interface{}) (interface{}, error) {
+	return fa.create(ctx, opt, nil)
+}
+
+func (fa *Factory) MustCreate() interface{} {
+	return fa.MustCreateWithOption(nil)
+}
+
+func (fa *Factory) MustCreateWithOption(opt map[string]interface{}) interface{} {
+	return fa.MustCreateWithContextAndOption(context.Background(), opt)
+}
+
+func (fa *Factory) MustCreateWithContextAndOption(ctx context.Context, opt map[string]interface{}) interface{} {
+	inst, err := fa.CreateWithContextAndOption(ctx, opt)
+	if err != nil {
+		panic(err)
+	}
+	return inst
+}
+
+// CreateWithTrace returns a new object with option and an option which holds
+// the final values of all exported attributes. Creating with the returned option
+// reproduces the same object.
+func (fa *Factory) CreateWithTrace(opt map[string]interface{}) (interface{}, map[string]interface{}, error) {
+	inst, err := fa.CreateWithOption(opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inst, fa.trace(inst), nil
+}
+
+// trace returns the values of all exported attributes of inst keyed by attribute name.
+func (fa *Factory) trace(inst interface{}) map[string]interface{} {
+	rv := reflect.Indirect(reflect.ValueOf(inst))
+	trace := make(map[string]interface{}, fa.numField)
+	for i := 0; i < fa.numField; i++ {
+		if fa.rt.Field(i).PkgPath != "" {
+			continue
+		}
+		trace[fa.attrGens[i].key] = rv.Field(i).Interface()
+	}
+	return trace
+}
+
+// CreateBestEffort creates a new object with option, continuing when a generator fails.
+// Attributes whose generators failed are left as they are, and all errors are returned.
+// Generators of sub factories are also continued, so that every broken branch is reported.
+func (fa *Factory) CreateBestEffort(opt map[string]interface{}) (interface{}, []error) {
+	var errs []error
+	pl := newPipeline()
+	pl.errs = &errs
+	inst, err := fa.create(context.Background(), opt, pl)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return inst, errs
+}
+
+// CreateSkipping creates a new object with option, leaving the attributes in skip zero value.
+// Neither generators nor default values are applied to them.
+func (fa *Factory) CreateSkipping(skip []string, opt map[string]interface{}) (interface{}, error) {
+	pl := newPipeline()
+	pl.skip = make(map[int]bool, len(skip))
+	for _, name := range skip {
+		idx, ok := fa.nameIndexMap[name]
+		if !ok {
+			return nil, errors.New("No such attribute name: " + name)
+		}
+		pl.skip[idx] = true
+	}
+	return fa.create(context.Background(), opt, pl)
+}
+
+// CreateWithCleanup creates a new object and saves it by save, then returns a function deleting it by del,
+// which can be registered by t.Cleanup. An error of del is passed to the observer registered by OnError.
+func (fa *Factory) CreateWithCleanup(save func(interface{}) error, del func(interface{}) error) (interface{}, func(), error) {
+	inst, err := fa.Create()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := save(inst); err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		if err := del(inst); err != nil && fa.onError != nil {
+			fa.onError(err)
+		}
+	}
+	return inst, cleanup, nil
+}
+
+// CreateOrZero returns a new object with option, or zero value of the model if creation is failed.
+// The error is passed to the observer registered by OnError.
+func (fa *Factory) CreateOrZero(opt map[string]interface{}) interface{} {
+	inst, err := fa.CreateWithOption(opt)
+	if err != nil {
+		if fa.onError != nil {
+			fa.onError(err)
+		}
+		return reflect.Zero(reflect.TypeOf(fa.model)).Interface()
+	}
+	return inst
+}
+
+// OnError registers an observer of errors discarded by CreateOrZero.
+func (fa *Factory) OnError(fn func(error)) *Factory {
+	fa.onError = fn
+	return fa
+}
+
+// OnRecursionLimit sets a callback invoked with the attribute name
+// when a recursive sub factory stops because the depth reaches the limit.
+func (fa *Factory) OnRecursionLimit(fn func(name string)) *Factory {
+	fa.onRecursionLimit = fn
+	return fa
+}
+
+func (fa *Factory) reachRecursionLimit(name string) {
+	if fa.onRecursionLimit != nil {
+		fa.onRecursionLimit(name)
+	}
+}
+
+/*
+Bind values of a new objects to a pointer to struct.
+
+ptr: a pointer to struct
+*/
+func (fa *Factory) Construct(ptr interface{}) error {
+	return fa.ConstructWithOption(ptr, nil)
+}
+
+/*
+Bind values of a new objects to a pointer to struct with option.
+
+ptr: a pointer to struct
+opt: attibute values
+*/
+func (fa *Factory) ConstructWithOption(ptr interface{}, opt map[string]interface{}) error {
+	return fa.ConstructWithContextAndOption(context.Background(), ptr, opt)
+}
+
+/*
+Bind values of a new objects to a pointer to struct with context and option.
+
+ctx: context object
+ptr: a pointer to struct
+opt: attibute values
+*/
+func (fa *Factory) ConstructWithContextAndOption(ctx context.Context, ptr interface{}, opt map[string]interface{}) error {
+	pt, err := fa.checkPtr(ptr)
+	if err != nil {
+		return err
+	}
+
+	inst := reflect.ValueOf(ptr).Elem()
+	_, err = fa.build(ctx, &inst, pt, opt, nil)
+	return err
+}
+
+/*
+Reset a struct to zero value and bind values of a new objects to it.
+This reuses the memory of the struct, so it is useful in a benchmark loop.
+
+ptr: a pointer to struct
+*/
+func (fa *Factory) CreateInto(ptr interface{}) error {
+	pt, err := fa.checkPtr(ptr)
+	if err != nil {
+		return err
+	}
+
+	inst := reflect.ValueOf(ptr).Elem()
+	inst.Set(reflect.Zero(pt))
+	_, err = fa.build(context.Background(), &inst, pt, nil, nil)
+	return err
+}
+
+// checkPtr returns the element type of ptr if it is a pointer to the model type.
+func (fa *Factory) checkPtr(ptr interface{}) (reflect.Type, error) {
+	pt := reflect.TypeOf(ptr)
+	if pt == nil || pt.Kind() != reflect.Ptr {
+		return nil, errors.New("ptr should be pointer type.")
+	}
+	pt = pt.Elem()
+	if pt.Name() != fa.modelName() {
+		return nil, errors.New("ptr type should be " + fa.modelName())
+	}
+	return pt, nil
+}
+
+func (fa *Factory) build(ctx context.Context, inst *reflect.Value, tp reflect.Type, opt map[string]interface{}, pl *pipeline) (_ interface{}, err error) {
+	if fa.abstract {
+		return nil, errors.New("abstract factory cannot be created directly")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if fa.metrics != nil {
+		start := time.Now()
+		defer func() {
+			if err == nil {
+				fa.metrics.addCreated(time.Since(start))
+			}
+		}()
+	}
+
+	// A panic while creating the object is recovered and returned as an error.
+	var args *argsStruct
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if args != nil && args.current != "" {
+			err = fmt.Errorf("panic while processing %v.%v: %v", fa.modelName(), args.current, r)
+		} else {
+			err = fmt.Errorf("panic while processing %v: %v", fa.modelName(), r)
+		}
+	}()
+
+	if fa.firstCreate != nil {
+		var err error
+		fa.firstCreate.once.Do(func() {
+			err = fa.callHook(fa.firstCreate.fn)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pl == nil {
+		pl = newPipeline()
+	}
+	if pl.parent == nil {
+		opt = mergeOverrides(ctx, opt)
+		if pl.seqs == nil {
+			pl.seqs = fa.seqs
+		}
+	}
+	if err := pl.checkCycle(fa); err != nil {
+		return nil, err
+	}
+	args = &argsStruct{}
+	args.pl = pl
+	args.ctx = ctx
+	args.fa = fa
+	args.opt = opt
+	args.done = make([]bool, fa.numField)
+	if fa.isPtr {
+		addr := (*inst).Addr()
+		args.rv = &addr
+	} else {
+		args.rv = inst
+	}
+
+	if fa.beforeCreate != nil {
+		if err := fa.callHook(func() error { return fa.beforeCreate(args) }); err != nil {
+			return nil, err
+		}
+	}
+
+	if fa.scalarGen != nil {
+		if err := fa.buildScalar(inst, args); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fa.buildAttrs(inst, tp, opt, args); err != nil {
+		return nil, err
+	}
+
+	for _, i := range fa.required {
+		if inst.Field(i).IsZero() {
+			return nil, errors.New("required attribute is zero value: " + fa.attrGens[i].key)
+		}
+	}
+
+	if fa.validator != nil {
+		if err := fa.validator(args.Instance()); err != nil {
+			return nil, err
+		}
+	}
+
+	if fa.onCreate != nil {
+		if err := fa.callHook(func() error { return fa.onCreate(args) }); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, hook := range fa.traitHooks {
+		if err := fa.callHook(func() error { return hook(args) }); err != nil {
+			return nil, err
+		}
+	}
+
+	if fa.replayLog != nil && pl.parent == nil {
+		if err := fa.replayLog.write(fa.trace(args.Instance())); err != nil {
+			return nil, err
+		}
+	}
+
+	if fa.isPtr {
+		return (*inst).Addr().Interface(), nil
+	}
+	return inst.Interface(), nil
+}
+
+// buildAttrs applies generators, default values and options to each attribute.
+func (fa *Factory) buildAttrs(inst *reflect.Value, tp reflect.Type, opt map[string]interface{}, args *argsStruct) error {
+	for _, i := range fa.sorted {
+		args.current = fa.attrGens[i].key
+		if fa.attrGens[i].skip || args.pl.skip[i] {
+			continue
+		}
+		if v, ok := opt[fa.attrGens[i].key]; ok {
+			// A generator passed as an option is applied instead of being set literally.
+			if gen, ok := v.(func(Args) (interface{}, error)); ok {
+				gv, err := gen(args)
+				if err != nil {
+					return err
+				}
+				if gv != nil {
+					inst.Field(i).Set(reflect.ValueOf(gv))
+				}
+			} else {
+				inst.Field(i).Set(reflect.ValueOf(v))
+			}
+		} else {
+			ag := fa.attrGens[i]
+			if ag.genFunc == nil {
+				if sf := fa.rt.Field(i); fa.defaultGen != nil && !ag.hasDefault() && sf.PkgPath == "" {
+					v, err := fa.defaultGen(sf, args)
+					if err != nil {
+						return err
+					}
+					if v != nil {
+						inst.Field(i).Set(reflect.ValueOf(v))
+					}
+				} else if !ag.isNil {
+					inst.Field(i).Set(reflect.ValueOf(ag.value))
+				}
+			} else {
+				var start time.Time
+				if fa.metrics != nil {
+					start = time.Now()
+				}
+				v, err := ag.genFunc(args)
+				if fa.metrics != nil {
+					fa.metrics.addAttr(ag.key, time.Since(start))
+				}
+				if err != nil {
+					if !args.pl.recordError(fmt.Errorf("%v.%v: %v", fa.modelName(), ag.key, err)) {
+						return err
+					}
+				} else if v != nil {
+					inst.Field(i).Set(reflect.ValueOf(v))
+				}
+			}
+		}
+		for _, tt := range fa.typeTransforms {
+			if fv := inst.Field(i); fv.Type() == tt.tp {
+				fv.Set(reflect.ValueOf(tt.fn(fv.Interface())))
+			}
+		}
+		args.done[i] = true
+	}
+
+	for k, v := range opt {
+		args.current = k
+		setValueWithAttrPath(inst, tp, k, v)
+	}
+	args.current = ""
+
+	return nil
 }
 
 func (fa *Factory) create(ctx context.Context, opt map[string]interface{}, pl *pipeline) (interface{}, error) {
@@ -2,8 +2,16 @@ package factory
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestFactory(t *testing.T) {
@@ -492,3 +500,1918 @@ func TestFactorySeqStringStartsAt1(t *testing.T) {
 		t.Errorf("the starting number for SeqString was %s, not 1", name)
 	}
 }
+
+func TestFactoryTagDefaults(t *testing.T) {
+	type User struct {
+		Name     string  `factory:"name;default=bluele"`
+		Status   string  `factory:";default=active"`
+		Age      int     `factory:"age;default=20"`
+		Score    float64 `factory:"score;default=1.5"`
+		IsAdmin  bool    `factory:"is_admin;default=true"`
+		Location string  `factory:"location;default=Osaka"`
+	}
+
+	var userFactory = NewFactory(&User{Location: "Tokyo"})
+
+	user := userFactory.MustCreate().(*User)
+	if user.Name != "bluele" {
+		t.Errorf("user.Name should be bluele, not %v", user.Name)
+	}
+	if user.Status != "active" {
+		t.Errorf("user.Status should be active, not %v", user.Status)
+	}
+	if user.Age != 20 {
+		t.Errorf("user.Age should be 20, not %v", user.Age)
+	}
+	if user.Score != 1.5 {
+		t.Errorf("user.Score should be 1.5, not %v", user.Score)
+	}
+	if !user.IsAdmin {
+		t.Error("user.IsAdmin should be true.")
+	}
+	if user.Location != "Tokyo" {
+		t.Errorf("user.Location should be Tokyo, not %v", user.Location)
+	}
+
+	user = userFactory.MustCreateWithOption(map[string]interface{}{"name": "jun"}).(*User)
+	if user.Name != "jun" {
+		t.Errorf("user.Name should be jun, not %v", user.Name)
+	}
+
+	var valueFactory = NewFactory(User{Location: "Tokyo"})
+	value := valueFactory.MustCreate().(User)
+	if value.Status != "active" || value.Age != 20 || value.Location != "Tokyo" {
+		t.Errorf("defaults of a value model should be applied: %+v", value)
+	}
+}
+
+func TestFactoryInvalidTagDefault(t *testing.T) {
+	type User struct {
+		Age int `factory:"age;default=twenty"`
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewFactory should panic")
+		}
+	}()
+	NewFactory(&User{})
+}
+
+type testShape interface {
+	Area() int
+}
+
+type testSquare struct {
+	Size int
+}
+
+func (s *testSquare) Area() int { return s.Size * s.Size }
+
+type testRect struct {
+	Width, Height int
+}
+
+func (r *testRect) Area() int { return r.Width * r.Height }
+
+func TestSubSliceFactoryUnion(t *testing.T) {
+	type Canvas struct {
+		Shapes []testShape
+	}
+
+	squareFactory := NewFactory(&testSquare{Size: 2})
+	rectFactory := NewFactory(&testRect{Width: 2, Height: 3})
+
+	canvasFactory := NewFactory(&Canvas{}).
+		SubSliceFactoryUnion("Shapes", []*Factory{squareFactory, rectFactory}, func() int { return 4 }, func(i int) int {
+			return i % 2
+		})
+
+	canvas := canvasFactory.MustCreate().(*Canvas)
+	if len(canvas.Shapes) != 4 {
+		t.Fatalf("len(canvas.Shapes) should be 4, not %v", len(canvas.Shapes))
+	}
+	for i, shape := range canvas.Shapes {
+		if i%2 == 0 {
+			if _, ok := shape.(*testSquare); !ok {
+				t.Errorf("canvas.Shapes[%v] should be *testSquare", i)
+			}
+		} else {
+			if _, ok := shape.(*testRect); !ok {
+				t.Errorf("canvas.Shapes[%v] should be *testRect", i)
+			}
+		}
+	}
+
+	canvasFactory = NewFactory(&Canvas{}).
+		SubSliceFactoryUnion("Shapes", []*Factory{squareFactory}, func() int { return 1 }, func(i int) int {
+			return 1
+		})
+	if _, err := canvasFactory.Create(); err == nil {
+		t.Error("an out of range factory index should return an error")
+	}
+
+	canvasFactory = NewFactory(&Canvas{}).
+		SubSliceFactoryUnion("Shapes", []*Factory{NewFactory(&Canvas{})}, func() int { return 1 }, func(i int) int {
+			return 0
+		})
+	if _, err := canvasFactory.Create(); err == nil {
+		t.Error("an unassignable element should return an error")
+	}
+}
+
+func TestFactorySetOrder(t *testing.T) {
+	type User struct {
+		Name string
+		ID   int
+	}
+
+	var userFactory = NewFactory(&User{}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			user := args.Instance().(*User)
+			return fmt.Sprintf("user-%d", user.ID), nil
+		}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SetOrder("ID")
+
+	user := userFactory.MustCreate().(*User)
+	if user.Name != "user-1" {
+		t.Errorf("user.Name should be user-1, not %v", user.Name)
+	}
+}
+
+func TestFactoryPriorityTag(t *testing.T) {
+	type User struct {
+		Name string
+		Slug string `factory:";priority=1"`
+		ID   int    `factory:"ID;priority=10"`
+	}
+
+	var userFactory = NewFactory(&User{}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			user := args.Instance().(*User)
+			return fmt.Sprintf("%v-%d", user.Slug, user.ID), nil
+		}).
+		Attr("Slug", func(args Args) (interface{}, error) {
+			return fmt.Sprintf("user%d", args.Instance().(*User).ID), nil
+		}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	user := userFactory.MustCreate().(*User)
+	if user.Name != "user1-1" {
+		t.Errorf("user.Name should be user1-1, not %v", user.Name)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewFactory should panic")
+		}
+	}()
+	NewFactory(&struct {
+		ID int `factory:";priority=high"`
+	}{})
+}
+
+func TestFactoryWithTypeTransform(t *testing.T) {
+	type Item struct {
+		Price    float64
+		Discount float64
+		Name     string
+	}
+
+	var itemFactory = NewFactory(&Item{Discount: 0.125}).
+		Attr("Price", func(args Args) (interface{}, error) {
+			return 10.456, nil
+		}).
+		WithTypeTransform(reflect.TypeOf(float64(0)), func(v interface{}) interface{} {
+			return v.(float64) * 100
+		}).
+		WithTypeTransform(reflect.TypeOf(float64(0)), func(v interface{}) interface{} {
+			return math.Round(v.(float64))
+		})
+
+	item := itemFactory.MustCreateWithOption(map[string]interface{}{"Name": "apple"}).(*Item)
+	if item.Price != 1046 || item.Discount != 13 || item.Name != "apple" {
+		t.Errorf("unexpected item: %+v", item)
+	}
+}
+
+func TestFactoryWithStrictWiring(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	gen := func(args Args) (interface{}, error) {
+		return "bluele", nil
+	}
+	NewFactory(&User{}).Attr("Name", gen).SeqString("Name", func(s string) (interface{}, error) {
+		return s, nil
+	})
+
+	userFactory := NewFactory(&User{}).WithStrictWiring().Attr("Name", gen).
+		Trait("anonymous", func(fa *Factory) {
+			fa.Attr("Name", func(args Args) (interface{}, error) {
+				return "anonymous", nil
+			})
+		})
+	user, err := userFactory.CreateWithTraits(nil, "anonymous")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.(*User).Name != "anonymous" {
+		t.Errorf("user.Name should be anonymous, not %v", user.(*User).Name)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SeqString should panic")
+		}
+	}()
+	userFactory.SeqString("Name", func(s string) (interface{}, error) {
+		return s, nil
+	})
+}
+
+func TestFactoryRecoverPanicAsError(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			return 1, nil
+		})
+
+	_, err := userFactory.Create()
+	if err == nil {
+		t.Fatal("an unassignable value should return an error")
+	}
+	if !strings.Contains(err.Error(), "User.Name") {
+		t.Errorf("error should contain the attribute name: %v", err)
+	}
+
+	var validatedFactory = NewFactory(&User{}).
+		WithValidator(func(inst interface{}) error {
+			var user *User
+			return errors.New(user.Name)
+		})
+	_, err = validatedFactory.Create()
+	if err == nil || !strings.Contains(err.Error(), "panic while processing User:") {
+		t.Errorf("a panic outside attributes should return an error, not %v", err)
+	}
+
+	var scalarFactory = NewScalarFactory([]int{}, func(args Args) (interface{}, error) {
+		var ids []int
+		return ids[1], nil
+	})
+	if _, err := scalarFactory.Create(); err == nil {
+		t.Error("a panic in the scalar generator should return an error")
+	}
+}
+
+func TestFactoryParentField(t *testing.T) {
+	type Post struct {
+		ID     int
+		UserID int
+	}
+	type User struct {
+		ID    int
+		Posts []*Post
+	}
+
+	var postFactory = NewFactory(&Post{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("UserID", func(args Args) (interface{}, error) {
+			return args.ParentField("ID")
+		})
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n * 10, nil
+		}).
+		SubSliceFactory("Posts", postFactory, func() int { return 2 })
+
+	user := userFactory.MustCreate().(*User)
+	for _, post := range user.Posts {
+		if post.UserID != user.ID {
+			t.Errorf("post.UserID should be %v, not %v", user.ID, post.UserID)
+		}
+	}
+
+	if _, err := postFactory.Create(); err == nil {
+		t.Error("ParentField without parent should return an error")
+	}
+}
+
+func TestFactoryParentSnapshot(t *testing.T) {
+	type Comment struct {
+		Summary string
+	}
+	type Post struct {
+		ID      int
+		Title   string
+		Comment *Comment
+		Author  string
+	}
+
+	var snapshot *Post
+	commentFactory := NewFactory(&Comment{}).
+		Attr("Summary", func(args Args) (interface{}, error) {
+			snapshot = args.ParentSnapshot().(*Post)
+			return fmt.Sprintf("%v:%v", snapshot.ID, snapshot.Title), nil
+		})
+	postFactory := NewFactory(&Post{ID: 1, Title: "hello"}).
+		SubFactory("Comment", commentFactory).
+		Attr("Author", func(args Args) (interface{}, error) {
+			return "bluele", nil
+		})
+
+	post := postFactory.MustCreate().(*Post)
+	if post.Comment.Summary != "1:hello" {
+		t.Errorf("post.Comment.Summary should be 1:hello, not %v", post.Comment.Summary)
+	}
+	if snapshot == post || snapshot.Author != "" {
+		t.Errorf("snapshot should be a copy at the moment: %+v", snapshot)
+	}
+
+	var rootSnapshot interface{} = "unset"
+	NewFactory(&Comment{}).
+		Attr("Summary", func(args Args) (interface{}, error) {
+			rootSnapshot = args.ParentSnapshot()
+			return "", nil
+		}).
+		MustCreate()
+	if rootSnapshot != nil {
+		t.Errorf("snapshot of a root object should be nil, not %v", rootSnapshot)
+	}
+}
+
+func TestFactoryAttrIf(t *testing.T) {
+	type User struct {
+		ID       int
+		Name     string
+		Nickname string
+	}
+
+	var userFactory = NewFactory(&User{Nickname: "anonymous"}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		AttrIf("Nickname", func(args Args) bool {
+			return args.Instance().(*User).ID%2 == 0
+		}, func(args Args) (interface{}, error) {
+			return "even", nil
+		})
+
+	user := userFactory.MustCreate().(*User)
+	if user.Nickname != "anonymous" {
+		t.Errorf("user.Nickname should be anonymous, not %v", user.Nickname)
+	}
+	user = userFactory.MustCreate().(*User)
+	if user.Nickname != "even" {
+		t.Errorf("user.Nickname should be even, not %v", user.Nickname)
+	}
+}
+
+func TestFactoryAttrScan(t *testing.T) {
+	type User struct {
+		Nickname sql.NullString
+		Age      *sql.NullInt64
+		Name     string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		AttrScan("Nickname", func(args Args) (interface{}, error) {
+			return "bluele", nil
+		}).
+		AttrScan("Age", func(args Args) (interface{}, error) {
+			return int64(20), nil
+		}).
+		AttrScan("Name", func(args Args) (interface{}, error) {
+			return "jun", nil
+		})
+
+	user := userFactory.MustCreate().(*User)
+	if user.Nickname != (sql.NullString{String: "bluele", Valid: true}) {
+		t.Errorf("unexpected user.Nickname: %+v", user.Nickname)
+	}
+	if user.Age == nil || *user.Age != (sql.NullInt64{Int64: 20, Valid: true}) {
+		t.Errorf("unexpected user.Age: %+v", user.Age)
+	}
+	if user.Name != "jun" {
+		t.Errorf("user.Name should be jun, not %v", user.Name)
+	}
+
+	_, err := NewFactory(&User{}).
+		AttrScan("Age", func(args Args) (interface{}, error) {
+			return "twenty", nil
+		}).
+		Create()
+	if err == nil {
+		t.Error("an invalid value should return an error")
+	}
+}
+
+func TestFactoryAttrValidate(t *testing.T) {
+	type User struct {
+		Age int
+	}
+
+	age := 20
+	var userFactory = NewFactory(&User{}).
+		AttrValidate("Age", func(args Args) (interface{}, error) {
+			return age, nil
+		}, func(v interface{}) error {
+			if v.(int) < 0 {
+				return errors.New("age should not be negative")
+			}
+			return nil
+		})
+
+	if user := userFactory.MustCreate().(*User); user.Age != 20 {
+		t.Errorf("user.Age should be 20, not %v", user.Age)
+	}
+
+	age = -1
+	_, err := userFactory.Create()
+	if err == nil || err.Error() != "User.Age is invalid: age should not be negative" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFactoryCreateInto(t *testing.T) {
+	type User struct {
+		ID       int
+		Name     string
+		Nickname string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	user := &User{Name: "bluele", Nickname: "blue"}
+	for i := 1; i <= 3; i++ {
+		if err := userFactory.CreateInto(user); err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != i {
+			t.Errorf("user.ID should be %v, not %v", i, user.ID)
+		}
+		if user.Name != "" || user.Nickname != "" {
+			t.Errorf("user should be reset, but got %+v", user)
+		}
+	}
+
+	if err := userFactory.CreateInto(User{}); err == nil {
+		t.Error("a non-pointer value should return an error")
+	}
+}
+
+func BenchmarkFactoryCreateInto(b *testing.B) {
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			return "bluele", nil
+		})
+
+	user := &User{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := userFactory.CreateInto(user); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFactorySeqSlug(t *testing.T) {
+	type Article struct {
+		Slug string
+		Code string
+	}
+
+	var articleFactory = NewFactory(&Article{}).
+		SeqSlug("Slug", "article").
+		SeqSlug("Code", "item", 3)
+
+	for i := 1; i <= 2; i++ {
+		article := articleFactory.MustCreate().(*Article)
+		if slug := fmt.Sprintf("article-%d", i); article.Slug != slug {
+			t.Errorf("article.Slug should be %v, not %v", slug, article.Slug)
+		}
+		if code := fmt.Sprintf("item-00%d", i); article.Code != code {
+			t.Errorf("article.Code should be %v, not %v", code, article.Code)
+		}
+	}
+}
+
+func TestFactoryContextPropagation(t *testing.T) {
+	type ctxKey int
+	const tenantKey ctxKey = 1
+
+	type Item struct {
+		Tenant string
+	}
+	type Group struct {
+		Tenant string
+		Items  []*Item
+		After  string
+	}
+	type User struct {
+		Tenant string
+		Group  *Group
+		After  string
+	}
+
+	tenant := func(args Args) (interface{}, error) {
+		v, _ := args.Context().Value(tenantKey).(string)
+		return v, nil
+	}
+
+	var itemFactory = NewFactory(&Item{}).Attr("Tenant", tenant)
+	var groupFactory = NewFactory(&Group{}).
+		Attr("Tenant", func(args Args) (interface{}, error) {
+			args.UpdateContext(context.WithValue(args.Context(), tenantKey, "group"))
+			return tenant(args)
+		}).
+		SubSliceFactory("Items", itemFactory, func() int { return 2 }).
+		Attr("After", tenant)
+	var userFactory = NewFactory(&User{}).
+		BeforeCreate(func(args Args) error {
+			args.UpdateContext(context.WithValue(args.Context(), tenantKey, "user"))
+			return nil
+		}).
+		Attr("Tenant", tenant).
+		SubFactory("Group", groupFactory).
+		Attr("After", tenant)
+
+	user := userFactory.MustCreate().(*User)
+	if user.Tenant != "user" {
+		t.Errorf("user.Tenant should be user, not %v", user.Tenant)
+	}
+	if user.Group.Tenant != "group" {
+		t.Errorf("user.Group.Tenant should be group, not %v", user.Group.Tenant)
+	}
+	for _, item := range user.Group.Items {
+		if item.Tenant != "group" {
+			t.Errorf("item.Tenant should be group, not %v", item.Tenant)
+		}
+	}
+	if user.Group.After != "group" {
+		t.Errorf("user.Group.After should be group, not %v", user.Group.After)
+	}
+	if user.After != "user" {
+		t.Errorf("user.After should be user, not %v", user.After)
+	}
+
+	if _, err := NewFactory(&User{}).BeforeCreate(func(args Args) error {
+		return errors.New("failed")
+	}).Create(); err == nil {
+		t.Error("BeforeCreate should abort creation")
+	}
+}
+
+func TestFactoryWithOverrides(t *testing.T) {
+	type Group struct {
+		Name string
+	}
+	type User struct {
+		Name  string
+		Role  string
+		Group *Group
+	}
+
+	userFactory := NewFactory(&User{Name: "bluele", Role: "member"}).
+		SubFactory("Group", NewFactory(&Group{Name: "default"}))
+
+	ctx := WithOverrides(context.Background(), map[string]interface{}{"Name": "jun", "Role": "admin"})
+	ctx = WithOverrides(ctx, map[string]interface{}{"Role": "owner"})
+	user, err := userFactory.CreateWithContextAndOption(ctx, map[string]interface{}{"Name": "explicit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := user.(*User); u.Name != "explicit" || u.Role != "owner" || u.Group.Name != "default" {
+		t.Errorf("unexpected user: %+v, %+v", u, u.Group)
+	}
+
+	if u := userFactory.MustCreate().(*User); u.Name != "bluele" || u.Role != "member" {
+		t.Errorf("overrides should not be applied without the context: %+v", u)
+	}
+}
+
+func TestFactoryAbstract(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+		Role string
+	}
+
+	var baseUserFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			return "bluele", nil
+		}).
+		Abstract()
+
+	if _, err := baseUserFactory.Create(); err == nil {
+		t.Error("abstract factory should not create an object")
+	}
+
+	var adminFactory = baseUserFactory.Clone().
+		Attr("Role", func(args Args) (interface{}, error) {
+			return "admin", nil
+		})
+
+	admin := adminFactory.MustCreate().(*User)
+	if admin.ID != 1 || admin.Name != "bluele" || admin.Role != "admin" {
+		t.Errorf("unexpected user: %+v", admin)
+	}
+
+	if _, err := baseUserFactory.Create(); err == nil {
+		t.Error("abstract factory should not be changed by its clone")
+	}
+	if _, err := adminFactory.Clone().Abstract().Create(); err == nil {
+		t.Error("abstract clone should not create an object")
+	}
+	if _, err := adminFactory.Create(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFactoryWithGeneratorOption(t *testing.T) {
+	type User struct {
+		ID    int
+		Token string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SetOrder("ID")
+
+	user := userFactory.MustCreateWithOption(map[string]interface{}{
+		"Token": func(args Args) (interface{}, error) {
+			return fmt.Sprintf("token-%d", args.Instance().(*User).ID), nil
+		},
+	}).(*User)
+	if user.Token != "token-1" {
+		t.Errorf("user.Token should be token-1, not %v", user.Token)
+	}
+
+	_, err := userFactory.CreateWithOption(map[string]interface{}{
+		"Token": func(args Args) (interface{}, error) {
+			return nil, errors.New("failed")
+		},
+	})
+	if err == nil {
+		t.Error("an error of generator option should abort creation")
+	}
+}
+
+func TestFactoryScopedSeq(t *testing.T) {
+	type Item struct {
+		BatchID string
+	}
+	type Order struct {
+		BatchID string
+		Items   []*Item
+	}
+
+	batchID := func(n int64) (interface{}, error) {
+		return fmt.Sprintf("batch-%d", n), nil
+	}
+
+	var itemFactory = NewFactory(&Item{}).ScopedSeq("BatchID", batchID)
+	var orderFactory = NewFactory(&Order{}).
+		ScopedSeq("BatchID", batchID).
+		SubSliceFactory("Items", itemFactory, func() int { return 3 })
+
+	for i := 1; i <= 2; i++ {
+		order := orderFactory.MustCreate().(*Order)
+		expected := fmt.Sprintf("batch-%d", i)
+		if order.BatchID != expected {
+			t.Errorf("order.BatchID should be %v, not %v", expected, order.BatchID)
+		}
+		for _, item := range order.Items {
+			if item.BatchID != expected {
+				t.Errorf("item.BatchID should be %v, not %v", expected, item.BatchID)
+			}
+		}
+	}
+}
+
+func TestFactoryRequire(t *testing.T) {
+	type User struct {
+		ID    int
+		Name  string
+		Email string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			return "bluele", nil
+		}).
+		Require("ID", "Name", "Email")
+
+	_, err := userFactory.Create()
+	if err == nil {
+		t.Fatal("a zero required attribute should return an error")
+	}
+	if !strings.Contains(err.Error(), "Email") {
+		t.Errorf("error should contain the attribute name: %v", err)
+	}
+
+	if _, err := userFactory.CreateWithOption(map[string]interface{}{"Email": "bluele@example.com"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFactoryAttrFromContext(t *testing.T) {
+	type ctxKey int
+	const tenantKey ctxKey = 1
+
+	type User struct {
+		Tenant string
+	}
+
+	var userFactory = NewFactory(&User{Tenant: "default"}).
+		AttrFromContext("Tenant", tenantKey, true)
+
+	ctx := context.WithValue(context.Background(), tenantKey, "every")
+	if user := userFactory.MustCreateWithContextAndOption(ctx, nil).(*User); user.Tenant != "every" {
+		t.Errorf("user.Tenant should be every, not %v", user.Tenant)
+	}
+	if user := userFactory.MustCreate().(*User); user.Tenant != "default" {
+		t.Errorf("user.Tenant should be default, not %v", user.Tenant)
+	}
+
+	var strictFactory = NewFactory(&User{}).
+		AttrFromContext("Tenant", tenantKey, false)
+	if _, err := strictFactory.Create(); err == nil {
+		t.Error("a missing context value should return an error")
+	}
+	ctx = context.WithValue(context.Background(), tenantKey, 1)
+	if _, err := strictFactory.CreateWithContext(ctx); err == nil {
+		t.Error("an unassignable context value should return an error")
+	}
+}
+
+func TestSubSliceFactoryCap(t *testing.T) {
+	type Group struct {
+		ID int
+	}
+	type User struct {
+		Groups []*Group
+	}
+
+	groupFactory := NewFactory(&Group{})
+	userFactory := NewFactory(&User{}).
+		SubSliceFactoryCap("Groups", groupFactory, func() int { return 2 }, func() int { return 5 })
+
+	user := userFactory.MustCreate().(*User)
+	if len(user.Groups) != 2 {
+		t.Errorf("len(user.Groups) should be 2, not %v", len(user.Groups))
+	}
+	if cap(user.Groups) != 5 {
+		t.Errorf("cap(user.Groups) should be 5, not %v", cap(user.Groups))
+	}
+
+	userFactory = NewFactory(&User{}).
+		SubSliceFactoryCap("Groups", groupFactory, func() int { return 2 }, func() int { return 1 })
+	if _, err := userFactory.Create(); err == nil {
+		t.Error("capacity less than length should return an error")
+	}
+}
+
+func TestNestedSliceFactory(t *testing.T) {
+	type Cell struct {
+		Label string
+	}
+	type Grid struct {
+		Matrix [][]int
+		Cells  [][]*Cell
+	}
+
+	var gridFactory = NewFactory(&Grid{}).
+		NestedSliceFactory("Matrix", func(args Args, i, j int) (interface{}, error) {
+			return i*10 + j, nil
+		}, func() int { return 2 }, func() int { return 3 }).
+		NestedSliceFactory("Cells", func(args Args, i, j int) (interface{}, error) {
+			return Cell{Label: fmt.Sprintf("%d-%d", i, j)}, nil
+		}, func() int { return 1 }, func() int { return 2 })
+
+	grid := gridFactory.MustCreate().(*Grid)
+	if !reflect.DeepEqual(grid.Matrix, [][]int{{0, 1, 2}, {10, 11, 12}}) {
+		t.Errorf("unexpected grid.Matrix: %v", grid.Matrix)
+	}
+	if len(grid.Cells) != 1 || grid.Cells[0][1].Label != "0-1" {
+		t.Errorf("unexpected grid.Cells: %v", grid.Cells)
+	}
+
+	_, err := NewFactory(&Grid{}).
+		NestedSliceFactory("Matrix", func(args Args, i, j int) (interface{}, error) {
+			return "a", nil
+		}, func() int { return 1 }, func() int { return 1 }).
+		Create()
+	if err == nil {
+		t.Error("an unassignable element should return an error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NestedSliceFactory should panic")
+		}
+	}()
+	NewFactory(&struct{ Row []int }{}).NestedSliceFactory("Row", nil, nil, nil)
+}
+
+func TestSubSliceFactoryNumbered(t *testing.T) {
+	type Line struct {
+		Number uint16
+	}
+	type Invoice struct {
+		Lines []*Line
+	}
+
+	invoiceFactory := NewFactory(&Invoice{}).
+		SubSliceFactoryNumbered("Lines", NewFactory(&Line{}), func() int { return 3 }, "Number")
+
+	for i := 0; i < 2; i++ {
+		invoice := invoiceFactory.MustCreate().(*Invoice)
+		for j, line := range invoice.Lines {
+			if line.Number != uint16(j+1) {
+				t.Errorf("line.Number should be %v, not %v", j+1, line.Number)
+			}
+		}
+	}
+}
+
+func TestSubSliceFactoryWithSpecial(t *testing.T) {
+	type Address struct {
+		Kind string
+	}
+	type User struct {
+		Addresses []*Address
+	}
+
+	size := 3
+	addressFactory := NewFactory(&Address{Kind: "secondary"})
+	userFactory := NewFactory(&User{}).
+		SubSliceFactoryWithSpecial("Addresses", addressFactory, func() int { return size },
+			map[string]interface{}{"Kind": "primary"}, map[string]interface{}{"Kind": "billing"})
+
+	user := userFactory.MustCreate().(*User)
+	for i, kind := range []string{"primary", "secondary", "billing"} {
+		if user.Addresses[i].Kind != kind {
+			t.Errorf("user.Addresses[%v].Kind should be %v, not %v", i, kind, user.Addresses[i].Kind)
+		}
+	}
+
+	size = 1
+	if user := userFactory.MustCreate().(*User); user.Addresses[0].Kind != "billing" {
+		t.Errorf("user.Addresses[0].Kind should be billing, not %v", user.Addresses[0].Kind)
+	}
+}
+
+func TestSubFactoryWithOption(t *testing.T) {
+	type Comment struct {
+		ParentType string
+		ParentID   int
+	}
+	type Post struct {
+		ID      int
+		Comment *Comment
+	}
+
+	commentFactory := NewFactory(&Comment{ParentType: "unknown"})
+	postFactory := NewFactory(&Post{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SubFactoryWithOption("Comment", commentFactory, map[string]interface{}{
+			"ParentType": "post",
+			"ParentID": func(args Args) (interface{}, error) {
+				return args.ParentField("ID")
+			},
+		})
+
+	post := postFactory.MustCreate().(*Post)
+	if post.Comment.ParentType != "post" {
+		t.Errorf("post.Comment.ParentType should be post, not %v", post.Comment.ParentType)
+	}
+	if post.Comment.ParentID != post.ID {
+		t.Errorf("post.Comment.ParentID should be %v, not %v", post.ID, post.Comment.ParentID)
+	}
+}
+
+func TestFactoryEmbedFactory(t *testing.T) {
+	type Audit struct {
+		CreatedBy string
+		Version   int
+	}
+	type User struct {
+		Audit
+		Name string
+	}
+	type Post struct {
+		*Audit
+		Title string
+	}
+
+	auditFactory := NewFactory(&Audit{Version: 1}).
+		Attr("CreatedBy", func(args Args) (interface{}, error) {
+			return "system", nil
+		})
+
+	user := NewFactory(&User{Name: "bluele"}).EmbedFactory("Audit", auditFactory).MustCreate().(*User)
+	if user.CreatedBy != "system" || user.Version != 1 || user.Name != "bluele" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	post := NewFactory(Post{}).EmbedFactory("Audit", auditFactory).MustCreate().(Post)
+	if post.Audit == nil || post.CreatedBy != "system" {
+		t.Errorf("unexpected post: %+v", post)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("EmbedFactory should panic")
+		}
+	}()
+	NewFactory(&User{}).EmbedFactory("Name", auditFactory)
+}
+
+type testNotifier interface {
+	Notify() string
+}
+
+type testEmailNotifier struct {
+	Address string
+}
+
+func (n *testEmailNotifier) Notify() string { return "email:" + n.Address }
+
+type testSlackNotifier struct {
+	Channel string
+}
+
+func (n testSlackNotifier) Notify() string { return "slack:" + n.Channel }
+
+func TestSubFactoryInterface(t *testing.T) {
+	type User struct {
+		Kind     string
+		Notifier testNotifier
+	}
+
+	userFactory := NewFactory(&User{}).
+		SubFactoryInterface("Notifier", map[string]*Factory{
+			"email": NewFactory(&testEmailNotifier{Address: "bluele@example.com"}),
+			"slack": NewFactory(testSlackNotifier{}).Attr("Channel", func(args Args) (interface{}, error) {
+				return "#general", nil
+			}),
+		}, func(args Args) string {
+			return args.Instance().(*User).Kind
+		})
+
+	for kind, expected := range map[string]string{"email": "email:bluele@example.com", "slack": "slack:#general"} {
+		user := userFactory.MustCreateWithOption(map[string]interface{}{"Kind": kind}).(*User)
+		if user.Notifier.Notify() != expected {
+			t.Errorf("user.Notifier should notify %v, not %v", expected, user.Notifier.Notify())
+		}
+	}
+
+	if _, err := userFactory.CreateWithOption(map[string]interface{}{"Kind": "sms"}); err == nil {
+		t.Error("an unknown key should return an error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SubFactoryInterface should panic")
+		}
+	}()
+	NewFactory(&User{}).SubFactoryInterface("Notifier", map[string]*Factory{
+		"email": NewFactory(testEmailNotifier{}),
+	}, nil)
+}
+
+func TestSubFactoryIf(t *testing.T) {
+	type Subscription struct {
+		Plan string
+	}
+	type User struct {
+		Premium      bool
+		Subscription *Subscription
+		Backup       Subscription
+	}
+
+	subscriptionFactory := NewFactory(&Subscription{Plan: "gold"})
+	isPremium := func(args Args) bool {
+		return args.Instance().(*User).Premium
+	}
+	userFactory := NewFactory(&User{}).
+		SubFactoryIf("Subscription", subscriptionFactory, isPremium).
+		SubFactoryIf("Backup", subscriptionFactory, isPremium)
+
+	user := userFactory.MustCreateWithOption(map[string]interface{}{"Premium": true}).(*User)
+	if user.Subscription == nil || user.Subscription.Plan != "gold" || user.Backup.Plan != "gold" {
+		t.Errorf("premium user should have subscriptions: %+v", user)
+	}
+
+	user = userFactory.MustCreate().(*User)
+	if user.Subscription != nil || user.Backup.Plan != "" {
+		t.Errorf("user should not have subscriptions: %+v", user)
+	}
+}
+
+func TestSubSliceFactoryWithCountOption(t *testing.T) {
+	type Item struct {
+		ID int
+	}
+	type Order struct {
+		Items []*Item
+	}
+
+	itemFactory := NewFactory(&Item{})
+	orderFactory := NewFactory(&Order{}).
+		SubSliceFactory("Items", itemFactory, func() int { return 2 })
+
+	if order := orderFactory.MustCreate().(*Order); len(order.Items) != 2 {
+		t.Errorf("len(order.Items) should be 2, not %v", len(order.Items))
+	}
+	order := orderFactory.MustCreateWithOption(map[string]interface{}{"Items.#": 5}).(*Order)
+	if len(order.Items) != 5 {
+		t.Errorf("len(order.Items) should be 5, not %v", len(order.Items))
+	}
+	if _, err := orderFactory.CreateWithOption(map[string]interface{}{"Items.#": "5"}); err == nil {
+		t.Error("a non-integer count should return an error")
+	}
+	for _, count := range []interface{}{int64(3), uint(3), int8(3)} {
+		order := orderFactory.MustCreateWithOption(map[string]interface{}{"Items.#": count}).(*Order)
+		if len(order.Items) != 3 {
+			t.Errorf("len(order.Items) should be 3 for %T, not %v", count, len(order.Items))
+		}
+	}
+	_, err := orderFactory.CreateWithOption(map[string]interface{}{"Items.#": -1})
+	if err == nil || !strings.Contains(err.Error(), "should not be negative") {
+		t.Errorf("a negative count should return an error, not %v", err)
+	}
+}
+
+func TestFactoryOnFirstCreate(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	var calls int
+	var userFactory = NewFactory(&User{}).
+		OnFirstCreate(func() error {
+			calls++
+			return nil
+		})
+	for i := 0; i < 3; i++ {
+		userFactory.MustCreate()
+	}
+	if calls != 1 {
+		t.Errorf("OnFirstCreate should be called once, but called %v times", calls)
+	}
+
+	userFactory = NewFactory(&User{}).
+		OnFirstCreate(func() error {
+			return errors.New("failed")
+		})
+	if _, err := userFactory.Create(); err == nil {
+		t.Error("an error of OnFirstCreate should abort the first creation")
+	}
+	if _, err := userFactory.Create(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubSliceFactoryElementsReferParent(t *testing.T) {
+	type Comment struct {
+		ID     int
+		PostID int
+	}
+	type Post struct {
+		ID       int
+		Comments []*Comment
+	}
+	type Draft struct {
+		Comments []*Comment
+		ID       int
+	}
+
+	commentFactory := NewFactory(&Comment{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("PostID", func(args Args) (interface{}, error) {
+			return args.ParentField("ID")
+		})
+
+	postFactory := NewFactory(&Post{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SubSliceFactory("Comments", commentFactory, func() int { return 3 })
+
+	for i := 0; i < 2; i++ {
+		post := postFactory.MustCreate().(*Post)
+		for _, comment := range post.Comments {
+			if comment.PostID != post.ID {
+				t.Errorf("comment.PostID should be %v, not %v", post.ID, comment.PostID)
+			}
+		}
+	}
+
+	draftFactory := NewFactory(&Draft{}).
+		SubSliceFactory("Comments", commentFactory, func() int { return 1 }).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+	_, err := draftFactory.Create()
+	if err == nil {
+		t.Fatal("reading a parent attribute not generated yet should return an error")
+	}
+	if !strings.Contains(err.Error(), "Draft.ID") {
+		t.Errorf("error should contain the attribute name: %v", err)
+	}
+}
+
+func TestFactoryWithPanicRecovery(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	newUserFactory := func() *Factory {
+		return NewFactory(&User{}).
+			OnCreate(func(args Args) error {
+				var user *User
+				user.Name = "bluele"
+				return nil
+			})
+	}
+
+	_, err := newUserFactory().Create()
+	if err == nil || !strings.Contains(err.Error(), "panic while processing User") {
+		t.Errorf("a panic in OnCreate should return an error by default, not %v", err)
+	}
+	if strings.Contains(err.Error(), "goroutine") {
+		t.Errorf("error should not contain the stack trace by default: %v", err)
+	}
+
+	_, err = newUserFactory().WithPanicRecovery().Create()
+	if err == nil {
+		t.Fatal("a panic in OnCreate should return an error")
+	}
+	if !strings.Contains(err.Error(), "goroutine") {
+		t.Errorf("error should contain the stack trace: %v", err)
+	}
+
+	_, err = NewFactory(&User{}).
+		BeforeCreate(func(args Args) error {
+			panic("failed")
+		}).
+		WithPanicRecovery().
+		Create()
+	if err == nil {
+		t.Error("a panic in BeforeCreate should return an error")
+	}
+}
+
+func TestSubRecursiveFactoryWithSeveralRecursiveAttributes(t *testing.T) {
+	type Group struct {
+		ID     int
+		Parent *Group
+	}
+	type User struct {
+		ID     int
+		A, B   string
+		C, D   int
+		Friend *User
+		Group  *Group
+	}
+
+	var groupFactory = NewFactory(&Group{})
+	groupFactory.SubRecursiveFactory("Parent", groupFactory, func() int { return 3 })
+
+	var userFactory = NewFactory(&User{})
+	userFactory.
+		SubRecursiveFactory("Friend", userFactory, func() int { return 1 }).
+		SubFactory("Group", groupFactory)
+
+	user := userFactory.MustCreate().(*User)
+	if user.Friend == nil || user.Friend.Friend != nil {
+		t.Error("user should have only one level of friend")
+	}
+
+	depth := 0
+	for group := user.Group; group != nil; group = group.Parent {
+		depth++
+	}
+	if depth != 4 {
+		t.Errorf("depth of user.Group should be 4, not %v", depth)
+	}
+
+	pl := newPipeline()
+	if len(pl.stacks) != 0 {
+		t.Errorf("stacks should not be allocated until used, but got %v", len(pl.stacks))
+	}
+}
+
+func TestSubRecursiveFactoryStacksSize(t *testing.T) {
+	type Node struct {
+		Next  *Node
+		Depth int
+	}
+	for i := 0; i < 100; i++ {
+		other := NewFactory(&Node{})
+		other.SubRecursiveFactory("Next", other, func() int { return 1 })
+	}
+
+	var sizes []int
+	nodeFactory := NewFactory(&Node{})
+	nodeFactory.Attr("Depth", func(args Args) (interface{}, error) {
+		sizes = append(sizes, len(args.pipeline().stacks))
+		return 0, nil
+	})
+	nodeFactory.SubRecursiveFactory("Next", nodeFactory, func() int { return 2 })
+	nodeFactory.MustCreate()
+
+	for _, size := range sizes {
+		if size > 1 {
+			t.Errorf("stacks should be as large as the number of recursive attributes, but got %v", size)
+		}
+	}
+}
+
+func TestSubRecursiveFactoryOnRecursionLimit(t *testing.T) {
+	type Group struct {
+		Parent *Group
+	}
+
+	var truncated []string
+	var groupFactory = NewFactory(&Group{}).
+		OnRecursionLimit(func(name string) {
+			truncated = append(truncated, name)
+		})
+	groupFactory.SubRecursiveFactory("Parent", groupFactory, func() int { return 2 })
+
+	groupFactory.MustCreate()
+	groupFactory.MustCreate()
+	expected := []string{"Parent", "Parent"}
+	if !reflect.DeepEqual(truncated, expected) {
+		t.Errorf("truncated attributes should be %v, not %v", expected, truncated)
+	}
+}
+
+func TestSubRecursiveFactoryWithDeadline(t *testing.T) {
+	type Node struct {
+		Depth int
+		Child *Node
+	}
+
+	var hasDeadline bool
+	var nodeFactory = NewFactory(&Node{})
+	nodeFactory.
+		Attr("Depth", func(args Args) (interface{}, error) {
+			_, hasDeadline = args.Deadline()
+			time.Sleep(time.Millisecond)
+			return 0, nil
+		}).
+		SubRecursiveFactory("Child", nodeFactory, func() int { return 10000 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := nodeFactory.CreateWithContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("creation should be aborted by the deadline, but got %v", err)
+	}
+	if !hasDeadline {
+		t.Error("args.Deadline should return the deadline of the context")
+	}
+}
+
+type testTeam struct {
+	Members []*testMember
+}
+
+type testMember struct {
+	Team *testTeam
+}
+
+func TestSubFactoryCycleDetected(t *testing.T) {
+	type Group struct {
+		Owner interface{}
+	}
+	type User struct {
+		Friend *User
+		Group  *Group
+	}
+
+	var userFactory = NewFactory(&User{})
+	groupFactory := NewFactory(&Group{}).SubFactory("Owner", userFactory)
+	userFactory.SubFactory("Group", groupFactory)
+
+	_, err := userFactory.Create()
+	if !errors.Is(err, ErrCycleDetected) {
+		t.Fatalf("error should be ErrCycleDetected, not %v", err)
+	}
+	if !strings.Contains(err.Error(), "User -> Group -> User") {
+		t.Errorf("error should name the chain of factories: %v", err)
+	}
+
+	var friendFactory = NewFactory(&User{})
+	friendFactory.SubRecursiveFactory("Friend", friendFactory, func() int { return 2 })
+	if _, err := friendFactory.Create(); err != nil {
+		t.Errorf("recursive sub factory should not be detected as a cycle: %v", err)
+	}
+
+	var memberFactory = NewFactory(&testMember{})
+	teamFactory := NewFactory(&testTeam{}).
+		SubRecursiveSliceFactory("Members", memberFactory, func() int { return 2 }, func() int { return 2 })
+	memberFactory.SubFactory("Team", teamFactory)
+	if _, err := memberFactory.Create(); err != nil {
+		t.Errorf("sub factory under a recursive sub factory should not be detected as a cycle: %v", err)
+	}
+}
+
+func TestFactoryComputeAttr(t *testing.T) {
+	type Order struct {
+		Summary  string
+		Price    int
+		Quantity int
+	}
+
+	var orderFactory = NewFactory(&Order{}).
+		ComputeAttr("Summary", []string{"Price", "Quantity"}, func(values map[string]interface{}) (interface{}, error) {
+			return fmt.Sprintf("%d x %d", values["Price"], values["Quantity"]), nil
+		}).
+		Attr("Price", func(args Args) (interface{}, error) {
+			return 100, nil
+		}).
+		Attr("Quantity", func(args Args) (interface{}, error) {
+			return 3, nil
+		})
+
+	order := orderFactory.MustCreate().(*Order)
+	if order.Summary != "100 x 3" {
+		t.Errorf("order.Summary should be 100 x 3, not %v", order.Summary)
+	}
+	order = orderFactory.MustCreateWithOption(map[string]interface{}{"Quantity": 5}).(*Order)
+	if order.Summary != "100 x 5" {
+		t.Errorf("order.Summary should be 100 x 5, not %v", order.Summary)
+	}
+
+	// SetOrder keeps the dependencies.
+	orderFactory.SetOrder("Summary")
+	if order := orderFactory.MustCreate().(*Order); order.Summary != "100 x 3" {
+		t.Errorf("order.Summary should be 100 x 3, not %v", order.Summary)
+	}
+}
+
+func TestFactoryAttrSum(t *testing.T) {
+	type Amount int64
+	type Order struct {
+		Total    Amount
+		Subtotal int
+		Tax      int32
+		Note     string
+	}
+
+	var orderFactory = NewFactory(&Order{Subtotal: 1000}).
+		AttrSum("Total", "Subtotal", "Tax").
+		Attr("Tax", func(args Args) (interface{}, error) {
+			return int32(args.Instance().(*Order).Subtotal / 10), nil
+		})
+
+	if order := orderFactory.MustCreate().(*Order); order.Total != 1100 {
+		t.Errorf("order.Total should be 1100, not %v", order.Total)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("AttrSum should panic")
+		}
+	}()
+	NewFactory(&Order{}).AttrSum("Total", "Subtotal", "Note")
+}
+
+func TestFactoryAttrHash(t *testing.T) {
+	type Checksum string
+	type Blob struct {
+		Checksum Checksum
+		Name     string
+		Size     int
+	}
+
+	var blobFactory = NewFactory(&Blob{Size: 3}).
+		AttrHash("Checksum", []string{"Name", "Size"}, func(b []byte) string {
+			return fmt.Sprintf("%x", sha256.Sum256(b))
+		}).
+		SeqString("Name", func(s string) (interface{}, error) {
+			return "blob-" + s, nil
+		})
+
+	blob := blobFactory.MustCreate().(*Blob)
+	expected := fmt.Sprintf("%x", sha256.Sum256([]byte("blob-1\n3\n")))
+	if string(blob.Checksum) != expected {
+		t.Errorf("blob.Checksum should be %v, not %v", expected, blob.Checksum)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("AttrHash should panic")
+		}
+	}()
+	NewFactory(&Blob{}).AttrHash("Size", []string{"Name"}, nil)
+}
+
+func TestFactoryComputeAttrWithCircularDependency(t *testing.T) {
+	type Order struct {
+		A, B string
+	}
+
+	identity := func(values map[string]interface{}) (interface{}, error) {
+		return "", nil
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("ComputeAttr should panic")
+		}
+	}()
+	NewFactory(&Order{}).
+		ComputeAttr("A", []string{"B"}, identity).
+		ComputeAttr("B", []string{"A"}, identity)
+}
+
+func TestFactoryCreateWithTrace(t *testing.T) {
+	type Group struct {
+		Name string
+	}
+	type User struct {
+		ID    int
+		Score float64
+		Tags  []string
+		Group *Group
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		FromQuick("Score", reflect.TypeOf(float64(0))).
+		SubFactory("Group", NewFactory(&Group{}).FromQuick("Name", reflect.TypeOf("")))
+
+	user, trace, err := userFactory.CreateWithTrace(map[string]interface{}{"Tags": []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := userFactory.MustCreateWithOption(trace)
+	if !reflect.DeepEqual(user, replayed) {
+		t.Errorf("replayed user should be %+v, not %+v", user, replayed)
+	}
+}
+
+func TestFactoryCreateBestEffort(t *testing.T) {
+	type Profile struct {
+		Bio string
+	}
+	type Group struct {
+		Name  string
+		Owner *Profile
+	}
+	type User struct {
+		Name    string
+		Profile *Profile
+		Group   *Group
+	}
+
+	brokenFactory := NewFactory(&Profile{}).
+		Attr("Bio", func(args Args) (interface{}, error) {
+			return nil, errors.New("failed")
+		})
+	groupFactory := NewFactory(&Group{Name: "admin"}).SubFactory("Owner", brokenFactory)
+	userFactory := NewFactory(&User{Name: "bluele"}).
+		SubFactory("Profile", NewFactory(&Profile{Bio: "hello"})).
+		SubFactory("Group", groupFactory).
+		Attr("Name", func(args Args) (interface{}, error) {
+			return nil, errors.New("failed")
+		})
+
+	inst, errs := userFactory.CreateBestEffort(nil)
+	user := inst.(*User)
+	if user.Profile == nil || user.Profile.Bio != "hello" {
+		t.Errorf("user.Profile should be created: %+v", user.Profile)
+	}
+	if user.Group == nil || user.Group.Name != "admin" || user.Group.Owner.Bio != "" {
+		t.Errorf("user.Group should be created partially: %+v", user.Group)
+	}
+	expected := []string{"User.Name: failed", "Profile.Bio: failed"}
+	if len(errs) != len(expected) {
+		t.Fatalf("errors should be %v, not %v", expected, errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("error should be %v, not %v", expected[i], err)
+		}
+	}
+
+	if _, err := userFactory.Create(); err == nil {
+		t.Error("Create should return an error")
+	}
+}
+
+func TestFactoryCreateSkipping(t *testing.T) {
+	type Group struct {
+		ID int
+	}
+	type User struct {
+		ID       int
+		Name     string
+		Password string
+		Group    *Group
+	}
+
+	var userFactory = NewFactory(&User{Name: "bluele", Password: "secret"}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SubFactory("Group", NewFactory(&Group{ID: 1}))
+
+	user, err := userFactory.CreateSkipping([]string{"ID", "Password"}, map[string]interface{}{"Password": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := user.(*User); u.ID != 0 || u.Password != "" || u.Name != "bluele" || u.Group.ID != 1 {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	if _, err := userFactory.CreateSkipping([]string{"Unknown"}, nil); err == nil {
+		t.Error("an unknown attribute should return an error")
+	}
+}
+
+func TestFactoryCreateWithCleanup(t *testing.T) {
+	type User struct {
+		ID int
+	}
+
+	db := make(map[int]*User)
+	save := func(v interface{}) error {
+		user := v.(*User)
+		if _, ok := db[user.ID]; ok {
+			return errors.New("duplicate")
+		}
+		db[user.ID] = user
+		return nil
+	}
+	var errs []error
+	del := func(v interface{}) error {
+		user := v.(*User)
+		if _, ok := db[user.ID]; !ok {
+			return errors.New("not found")
+		}
+		delete(db, user.ID)
+		return nil
+	}
+	userFactory := NewFactory(&User{ID: 1}).OnError(func(err error) {
+		errs = append(errs, err)
+	})
+
+	user, cleanup, err := userFactory.CreateWithCleanup(save, del)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db[1] != user {
+		t.Error("user should be saved")
+	}
+	if _, _, err := userFactory.CreateWithCleanup(save, del); err == nil {
+		t.Error("an error of save should be returned")
+	}
+
+	cleanup()
+	if len(db) != 0 {
+		t.Error("user should be deleted")
+	}
+	cleanup()
+	if len(errs) != 1 {
+		t.Errorf("an error of del should be passed to OnError, but got %v", errs)
+	}
+}
+
+func TestFactoryCreateOrZero(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	var errs []error
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		OnError(func(err error) {
+			errs = append(errs, err)
+		})
+
+	if user := userFactory.CreateOrZero(nil).(*User); user.ID != 1 {
+		t.Errorf("user.ID should be 1, not %v", user.ID)
+	}
+
+	user, ok := userFactory.CreateOrZero(map[string]interface{}{"Name": 1}).(*User)
+	if !ok || user != nil {
+		t.Errorf("user should be nil *User, not %#v", user)
+	}
+	if len(errs) != 1 {
+		t.Errorf("OnError should be called once, but called %v times", len(errs))
+	}
+
+	var valueFactory = NewFactory(User{Name: "bluele"})
+	if v := valueFactory.CreateOrZero(map[string]interface{}{"Name": 1}).(User); v != (User{}) {
+		t.Errorf("user should be zero value, not %v", v)
+	}
+}
+
+func TestFactorySeqIntStep(t *testing.T) {
+	type User struct {
+		ID   int
+		Rank int
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqIntStep("ID", 100, 10, func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SeqIntStep("Rank", 0, -1, func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	for i, expected := range []User{{100, 0}, {110, -1}, {120, -2}} {
+		if user := userFactory.MustCreate().(*User); *user != expected {
+			t.Errorf("user %v should be %+v, not %+v", i, expected, *user)
+		}
+	}
+}
+
+func TestFactorySeqGroup(t *testing.T) {
+	type Item struct {
+		ID   int64
+		Code string
+	}
+
+	var itemFactory = NewFactory(&Item{}).
+		SeqGroup([]string{"ID", "Code"}, func(n int64, name string) (interface{}, error) {
+			if name == "Code" {
+				return fmt.Sprintf("ITEM-%03d", n), nil
+			}
+			return n, nil
+		})
+
+	for i, expected := range []Item{{1, "ITEM-001"}, {2, "ITEM-002"}} {
+		if item := itemFactory.MustCreate().(*Item); *item != expected {
+			t.Errorf("item %v should be %+v, not %+v", i, expected, *item)
+		}
+	}
+
+	item := itemFactory.MustCreateWithOption(map[string]interface{}{"ID": int64(100)}).(*Item)
+	if item.ID != 100 || item.Code != "ITEM-003" {
+		t.Errorf("unexpected item: %+v", item)
+	}
+}
+
+func TestFactorySequenceState(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+		Slug string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SeqString("Name", func(s string) (interface{}, error) {
+			return "user-" + s, nil
+		})
+
+	userFactory.MustCreate()
+	userFactory.MustCreate()
+	state := userFactory.SequenceState()
+	if !reflect.DeepEqual(state, map[string]int64{"ID": 2, "Name": 2}) {
+		t.Errorf("unexpected sequence state: %v", state)
+	}
+
+	userFactory.RestoreSequenceState(map[string]int64{"ID": 100, "Name": 200})
+	user := userFactory.MustCreate().(*User)
+	if user.ID != 101 || user.Name != "user-201" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	userFactory.RestoreSequenceState(state)
+	if user := userFactory.MustCreate().(*User); user.ID != 3 {
+		t.Errorf("user.ID should be 3, not %v", user.ID)
+	}
+
+	userFactory.ResetSequences()
+	if user := userFactory.MustCreate().(*User); user.ID != 1 {
+		t.Errorf("user.ID should be 1, not %v", user.ID)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RestoreSequenceState should panic for a non-sequence attribute")
+		}
+	}()
+	userFactory.RestoreSequenceState(map[string]int64{"Slug": 1})
+}
+
+func TestSubRecursiveSliceFactoryWithPointerElements(t *testing.T) {
+	type Node struct {
+		ID       int
+		Children []*Node
+	}
+	type ValueNode struct {
+		ID       int
+		Children []ValueNode
+	}
+
+	countNodes := func(node *Node) int {
+		var count func(*Node) int
+		count = func(n *Node) int {
+			c := 1
+			for _, child := range n.Children {
+				c += count(child)
+			}
+			return c
+		}
+		return count(node)
+	}
+
+	for name, model := range map[string]interface{}{"value model": Node{}, "pointer model": &Node{}} {
+		t.Run(name, func(t *testing.T) {
+			var nodeFactory = NewFactory(model)
+			nodeFactory.
+				SeqInt("ID", func(n int) (interface{}, error) {
+					return n, nil
+				}).
+				SubRecursiveSliceFactory("Children", nodeFactory, func() int { return 2 }, func() int { return 2 })
+
+			inst, err := nodeFactory.Create()
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, ok := inst.(*Node)
+			if !ok {
+				v := inst.(Node)
+				root = &v
+			}
+			// 1 root + 2 children + 4 grandchildren
+			if n := countNodes(root); n != 7 {
+				t.Errorf("tree should have 7 nodes, not %v", n)
+			}
+		})
+	}
+
+	var valueNodeFactory = NewFactory(&ValueNode{})
+	valueNodeFactory.SubRecursiveSliceFactory("Children", valueNodeFactory, func() int { return 2 }, func() int { return 1 })
+	if root := valueNodeFactory.MustCreate().(*ValueNode); len(root.Children) != 2 {
+		t.Errorf("len(root.Children) should be 2, not %v", len(root.Children))
+	}
+}
+
+func TestFactoryCreateInvalid(t *testing.T) {
+	type User struct {
+		ID    int
+		Name  string
+		Email string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			return "bluele", nil
+		}).
+		Attr("Email", func(args Args) (interface{}, error) {
+			return "bluele@example.com", nil
+		}).
+		WithValidator(func(inst interface{}) error {
+			if !strings.Contains(inst.(*User).Email, "@") {
+				return errors.New("invalid email")
+			}
+			if inst.(*User).Name == "" {
+				return errors.New("empty name")
+			}
+			return nil
+		})
+
+	if _, err := userFactory.CreateWithOption(map[string]interface{}{"Email": "invalid"}); err == nil {
+		t.Error("an invalid object should be rejected by the validator")
+	}
+
+	inst, failures, err := userFactory.CreateInvalid(map[string]func(Args) (interface{}, error){
+		"Name": func(args Args) (interface{}, error) {
+			return "", nil
+		},
+		"Email": func(args Args) (interface{}, error) {
+			return "invalid", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || failures["Name"] == nil || failures["Name"].Error() != "empty name" ||
+		failures["Email"] == nil || failures["Email"].Error() != "invalid email" {
+		t.Errorf("failures of the validator should be keyed by attributes, not %v", failures)
+	}
+	user := inst.(*User)
+	if user.Email != "invalid" {
+		t.Errorf("user.Email should be invalid, not %v", user.Email)
+	}
+	if user.Name != "" {
+		t.Errorf("user.Name should be empty, not %v", user.Name)
+	}
+	if user.ID != 2 {
+		t.Errorf("the rest of user should stay valid, but user.ID is %v", user.ID)
+	}
+
+	_, _, err = userFactory.CreateInvalid(map[string]func(Args) (interface{}, error){
+		"Email": func(args Args) (interface{}, error) {
+			return "valid@example.com", nil
+		},
+	})
+	if err == nil {
+		t.Error("an error should be returned if the validator accepts the object")
+	}
+
+	if _, err := userFactory.Create(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubSliceFactoryFromField(t *testing.T) {
+	type LineItem struct {
+		ID int
+	}
+	type Order struct {
+		Items     []*LineItem
+		ItemCount int
+	}
+
+	itemFactory := NewFactory(&LineItem{})
+	orderFactory := NewFactory(&Order{}).
+		SubSliceFactoryFromField("Items", itemFactory, "ItemCount").
+		SeqInt("ItemCount", func(n int) (interface{}, error) {
+			return n, nil
+		})
+
+	for i := 1; i <= 3; i++ {
+		order := orderFactory.MustCreate().(*Order)
+		if len(order.Items) != order.ItemCount || order.ItemCount != i {
+			t.Errorf("len(order.Items) should be %v, not %v", order.ItemCount, len(order.Items))
+		}
+	}
+
+	order := orderFactory.MustCreateWithOption(map[string]interface{}{"ItemCount": 5}).(*Order)
+	if len(order.Items) != 5 {
+		t.Errorf("len(order.Items) should be 5, not %v", len(order.Items))
+	}
+}
+
+func TestSubSliceFactoryFromNonIntegerField(t *testing.T) {
+	type LineItem struct{}
+	type Order struct {
+		Items     []*LineItem
+		ItemCount string
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SubSliceFactoryFromField should panic")
+		}
+	}()
+	NewFactory(&Order{}).SubSliceFactoryFromField("Items", NewFactory(&LineItem{}), "ItemCount")
+}
@@ -0,0 +1,91 @@
+package factory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// UpdateGolden makes AssertGolden write golden files instead of comparing with them.
+// To update them by a flag, define the flag in the test package and set it in TestMain:
+//
+//	var update = flag.Bool("update", false, "update golden files")
+//
+//	func TestMain(m *testing.M) {
+//		flag.Parse()
+//		factory.UpdateGolden = *update
+//		os.Exit(m.Run())
+//	}
+var UpdateGolden = false
+
+// AssertGolden creates a new object with option and compares its JSON with the golden file at path.
+// Sequences of the factory, its sub factories and AutoFill start from the first value in each call,
+// so that the output is stable.
+func (fa *Factory) AssertGolden(t testing.TB, path string, opt map[string]interface{}) {
+	t.Helper()
+	nfa := fa.withFreshSequences()
+	inst, err := nfa.CreateWithOption(opt)
+	if err != nil {
+		t.Fatalf("cannot create an object for %v: %v", path, err)
+	}
+	b, err := nfa.exportJSON(inst)
+	if err != nil {
+		t.Fatalf("cannot marshal an object for %v: %v", path, err)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		t.Fatalf("cannot marshal an object for %v: %v", path, err)
+	}
+	buf.WriteByte('\n')
+	actual := buf.String()
+
+	if UpdateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("cannot update %v: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("cannot update %v: %v", path, err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read %v: %v", path, err)
+	}
+	if string(expected) != actual {
+		t.Errorf("object doesn't match %v:\n%v", path, lineDiff(string(expected), actual))
+	}
+}
+
+// lineDiff returns lines which differ between expected and actual,
+// prefixed with "-" for expected and "+" for actual.
+func lineDiff(expected, actual string) string {
+	el := strings.Split(expected, "\n")
+	al := strings.Split(actual, "\n")
+	var buf bytes.Buffer
+	for i := 0; i < len(el) || i < len(al); i++ {
+		var e, a string
+		if i < len(el) {
+			e = el[i]
+		}
+		if i < len(al) {
+			a = al[i]
+		}
+		if e == a {
+			continue
+		}
+		if i < len(el) {
+			fmt.Fprintf(&buf, "%4d - %v\n", i+1, e)
+		}
+		if i < len(al) {
+			fmt.Fprintf(&buf, "%4d + %v\n", i+1, a)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,97 @@
+package factory
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...interface{}) {
+	r.TB.Fatalf(format, args...)
+}
+
+func TestFactoryAssertGolden(t *testing.T) {
+	type User struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	var userFactory = NewFactory(&User{Name: "bluele"}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+	path := filepath.Join(t.TempDir(), "testdata", "user.golden")
+
+	UpdateGolden = true
+	userFactory.AssertGolden(t, path, nil)
+	UpdateGolden = false
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{\n  \"id\": 1,\n  \"name\": \"bluele\"\n}\n"; string(b) != expected {
+		t.Errorf("golden file should be %q, not %q", expected, b)
+	}
+
+	userFactory.MustCreate()
+	userFactory.AssertGolden(t, path, nil)
+
+	rec := &recordingTB{TB: t}
+	userFactory.AssertGolden(rec, path, map[string]interface{}{"Name": "jun"})
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], `3 +   "name": "jun"`) {
+		t.Errorf("a mismatch should be reported with a diff, but got %v", rec.errors)
+	}
+}
+
+func TestFactoryAssertGoldenSubFactory(t *testing.T) {
+	type Comment struct {
+		ID   int    `json:"id"`
+		Body string `json:"body"`
+	}
+	type Post struct {
+		Title    string     `json:"title"`
+		Comments []*Comment `json:"comments"`
+	}
+
+	var commentFactory = NewFactory(&Comment{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		AutoFill()
+	var postFactory = NewFactory(&Post{}).
+		SubSliceFactory("Comments", commentFactory, func() int { return 2 }).
+		AutoFill()
+	path := filepath.Join(t.TempDir(), "post.golden")
+
+	UpdateGolden = true
+	postFactory.AssertGolden(t, path, nil)
+	UpdateGolden = false
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"title": "Title-1"`) || !strings.Contains(string(b), `"body": "Body-2"`) {
+		t.Errorf("golden file should start sequences from the first value: %s", b)
+	}
+
+	postFactory.MustCreate()
+	rec := &recordingTB{TB: t}
+	postFactory.AssertGolden(rec, path, nil)
+	postFactory.AssertGolden(rec, path, nil)
+	if len(rec.errors) != 0 {
+		t.Errorf("sequences of sub factories and AutoFill should start over, but got %v", rec.errors)
+	}
+}
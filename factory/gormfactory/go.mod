@@ -0,0 +1,12 @@
+module github.com/everytv/factory-go/factory/gormfactory
+
+go 1.15
+
+require (
+	github.com/everytv/factory-go v0.0.0-20261015064456-ddd34a4634a6
+	github.com/jinzhu/gorm v1.9.16
+)
+
+// The factory package in this repository is used while developing gormfactory.
+// It is ignored by modules depending on gormfactory.
+replace github.com/everytv/factory-go => ../..
@@ -0,0 +1,33 @@
+// Package gormfactory provides helpers to persist objects created by factories with GORM.
+package gormfactory
+
+import (
+	"reflect"
+
+	"github.com/everytv/factory-go/factory"
+	"github.com/jinzhu/gorm"
+)
+
+// CreateAndSave creates a new object with option and saves it by db.Create.
+// GORM always receives a pointer, and the object is returned in the same form as the factory creates.
+func CreateAndSave(fa *factory.Factory, db *gorm.DB, opt map[string]interface{}) (interface{}, error) {
+	inst, err := fa.CreateWithOption(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(inst)
+	if rv.Kind() == reflect.Ptr {
+		if err := db.Create(inst).Error; err != nil {
+			return nil, err
+		}
+		return inst, nil
+	}
+
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	if err := db.Create(ptr.Interface()).Error; err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+}
@@ -0,0 +1,77 @@
+package gormfactory
+
+import (
+	"testing"
+
+	"github.com/everytv/factory-go/factory"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+type User struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCreateAndSave(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	userFactory := factory.NewFactory(&User{}).
+		Attr("Name", func(args factory.Args) (interface{}, error) {
+			return "bluele", nil
+		})
+
+	inst, err := CreateAndSave(userFactory, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := inst.(*User)
+	if user.ID == 0 {
+		t.Error("user.ID should be set by GORM")
+	}
+
+	var saved User
+	if err := db.First(&saved, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Name != "bluele" {
+		t.Errorf("saved.Name should be bluele, not %v", saved.Name)
+	}
+}
+
+func TestCreateAndSaveWithValueModel(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	userFactory := factory.NewFactory(User{Name: "jun"})
+
+	inst, err := CreateAndSave(userFactory, db, map[string]interface{}{"Name": "bluele"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, ok := inst.(User)
+	if !ok {
+		t.Fatal("It should be User type.")
+	}
+	if user.ID == 0 || user.Name != "bluele" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	var count int
+	db.Model(&User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("count should be 1, not %v", count)
+	}
+}
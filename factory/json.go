@@ -0,0 +1,207 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// WithJSONMarshalFunc registers a function to marshal the attribute in JSON export.
+// Attributes without a registered function are marshalled by encoding/json.
+func (fa *Factory) WithJSONMarshalFunc(name string, fn func(interface{}) (json.RawMessage, error)) *Factory {
+	fa.checkIdx(name)
+	if fa.jsonFuncs == nil {
+		fa.jsonFuncs = make(map[string]func(interface{}) (json.RawMessage, error))
+	}
+	fa.jsonFuncs[name] = fn
+	return fa
+}
+
+// CreateJSON creates a new object with option and returns it as JSON.
+func (fa *Factory) CreateJSON(opt map[string]interface{}) ([]byte, error) {
+	inst, err := fa.create(context.Background(), opt, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fa.exportJSON(inst)
+}
+
+// exportJSON encodes an object created by this factory as a JSON object in the same way as encoding/json.
+// Values of attributes registered by WithJSONMarshalFunc are replaced with the results of their functions.
+func (fa *Factory) exportJSON(inst interface{}) ([]byte, error) {
+	b, err := json.Marshal(inst)
+	if err != nil || len(fa.jsonFuncs) == 0 {
+		return b, err
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(inst))
+	custom := make(map[string]json.RawMessage, len(fa.jsonFuncs))
+	for i := 0; i < fa.numField; i++ {
+		fn, ok := fa.jsonFuncs[fa.attrGens[i].key]
+		if !ok {
+			continue
+		}
+		key, ok := jsonFieldName(fa.rt.Field(i))
+		if !ok {
+			continue
+		}
+		cb, err := fn(rv.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(cb) {
+			return nil, fmt.Errorf("invalid JSON for %v: %s", fa.attrGens[i].key, cb)
+		}
+		custom[key] = cb
+	}
+
+	// Keys are written in the order of encoding/json.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		key := t.(string)
+		if cb, ok := custom[key]; ok {
+			value = cb
+		}
+		kb, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonFieldName returns the key of the field in JSON, and false if it isn't exported.
+func jsonFieldName(sf reflect.StructField) (string, bool) {
+	if sf.PkgPath != "" {
+		return "", false
+	}
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return sf.Name, true
+}
+
+// JSONSchema returns a JSON Schema of objects exported by CreateJSON.
+// Attributes registered by Require are listed as required.
+func (fa *Factory) JSONSchema() ([]byte, error) {
+	schema := typeSchema(fa.rt, map[reflect.Type]bool{})
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = fa.modelName()
+
+	var required []string
+	for _, i := range fa.required {
+		if key, ok := jsonFieldName(fa.rt.Field(i)); ok {
+			required = append(required, key)
+		}
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return json.Marshal(schema)
+}
+
+// typeSchema returns a JSON Schema of the type.
+// visiting holds struct types being processed to stop at recursive types.
+func typeSchema(tp reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	for tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch tp.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if tp.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes []byte as a base64 string.
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(tp.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(tp.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[tp] {
+			return map[string]interface{}{"type": "object"}
+		}
+		visiting[tp] = true
+		defer delete(visiting, tp)
+
+		properties := make(map[string]interface{})
+		for i := 0; i < tp.NumField(); i++ {
+			sf := tp.Field(i)
+			if key, ok := jsonFieldName(sf); ok {
+				properties[key] = typeSchema(sf.Type, visiting)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
+
+// SubFactoryJSON creates a sub object and sets it to the attribute as JSON, like a jsonb column.
+// The attribute should be a string, []byte or json.RawMessage.
+func (fa *Factory) SubFactoryJSON(name string, sub *Factory) *Factory {
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	isBytes := tp.Kind() == reflect.Slice && tp.Elem().Kind() == reflect.Uint8
+	if tp.Kind() != reflect.String && !isBytes {
+		panic("Attribute should be string or []byte: " + name)
+	}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		pipeline := args.pipeline()
+		ret, err := sub.create(args.Context(), nil, pipeline.Next(args))
+		if err != nil {
+			return nil, err
+		}
+		var b []byte
+		if sub.scalarGen != nil {
+			b, err = json.Marshal(ret)
+		} else {
+			b, err = sub.exportJSON(ret)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal %v: %v", name, err)
+		}
+		if isBytes {
+			return reflect.ValueOf(b).Convert(tp).Interface(), nil
+		}
+		return reflect.ValueOf(string(b)).Convert(tp).Interface(), nil
+	})
+	return fa
+}
@@ -0,0 +1,176 @@
+package factory
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFactoryCreateJSON(t *testing.T) {
+	type User struct {
+		ID      int    `json:"id"`
+		Name    string `json:"name"`
+		Balance int64  `json:"balance"`
+		Secret  string `json:"-"`
+		Email   string
+		private string
+	}
+
+	var userFactory = NewFactory(&User{Name: "bluele", Secret: "secret", Email: "bluele@example.com"}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("Balance", func(args Args) (interface{}, error) {
+			return int64(1050), nil
+		}).
+		WithJSONMarshalFunc("Balance", func(v interface{}) (json.RawMessage, error) {
+			cents := v.(int64)
+			return json.Marshal(fmt.Sprintf("%d.%02d", cents/100, cents%100))
+		})
+
+	b, err := userFactory.CreateJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":1,"name":"bluele","balance":"10.50","Email":"bluele@example.com"}`
+	if string(b) != expected {
+		t.Errorf("json should be %v, not %v", expected, string(b))
+	}
+}
+
+func TestFactoryCreateJSONTagOptions(t *testing.T) {
+	type Timestamps struct {
+		CreatedBy string `json:"created_by"`
+	}
+	type User struct {
+		Timestamps
+		ID    int    `json:"id,string"`
+		Name  string `json:"name,omitempty"`
+		Email string `json:"email,omitempty"`
+	}
+
+	var userFactory = NewFactory(&User{ID: 1, Email: "bluele@example.com", Timestamps: Timestamps{CreatedBy: "admin"}}).
+		WithJSONMarshalFunc("Email", func(v interface{}) (json.RawMessage, error) {
+			return json.Marshal(strings.ToUpper(v.(string)))
+		})
+
+	b, err := userFactory.CreateJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"created_by":"admin","id":"1","email":"BLUELE@EXAMPLE.COM"}`
+	if string(b) != expected {
+		t.Errorf("json should be %v, not %v", expected, string(b))
+	}
+
+	userFactory.WithJSONMarshalFunc("Email", func(v interface{}) (json.RawMessage, error) {
+		return json.RawMessage(`{"broken"`), nil
+	})
+	if _, err := userFactory.CreateJSON(nil); err == nil {
+		t.Error("invalid JSON of a marshal func should return an error")
+	}
+}
+
+func TestFactoryJSONSchema(t *testing.T) {
+	type Group struct {
+		Name string `json:"name"`
+	}
+	type User struct {
+		ID        int               `json:"id"`
+		Name      string            `json:"name"`
+		Score     float64           `json:"score"`
+		IsAdmin   bool              `json:"is_admin"`
+		Groups    []*Group          `json:"groups"`
+		Attrs     map[string]string `json:"attrs"`
+		Friend    *User             `json:"friend"`
+		CreatedAt time.Time         `json:"created_at"`
+		Secret    string            `json:"-"`
+	}
+
+	var userFactory = NewFactory(&User{}).Require("ID", "Name")
+	b, err := userFactory.JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Title      string                            `json:"title"`
+		Type       string                            `json:"type"`
+		Required   []string                          `json:"required"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Title != "User" || schema.Type != "object" {
+		t.Errorf("unexpected schema: %s", b)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"id", "name"}) {
+		t.Errorf("schema.Required should be [id name], not %v", schema.Required)
+	}
+	for key, tp := range map[string]string{
+		"id":         "integer",
+		"name":       "string",
+		"score":      "number",
+		"is_admin":   "boolean",
+		"groups":     "array",
+		"attrs":      "object",
+		"friend":     "object",
+		"created_at": "string",
+	} {
+		if schema.Properties[key]["type"] != tp {
+			t.Errorf("type of %v should be %v, not %v", key, tp, schema.Properties[key]["type"])
+		}
+	}
+	if _, ok := schema.Properties["Secret"]; ok {
+		t.Error("ignored field should not be in schema")
+	}
+	items := schema.Properties["groups"]["items"].(map[string]interface{})
+	if _, ok := items["properties"].(map[string]interface{})["name"]; !ok {
+		t.Errorf("schema of nested struct should have properties: %v", items)
+	}
+}
+
+func TestSubFactoryJSON(t *testing.T) {
+	type Metadata struct {
+		Source string `json:"source"`
+		Score  int    `json:"score"`
+	}
+	type Event struct {
+		Metadata json.RawMessage
+		Raw      []byte
+		Text     string
+	}
+
+	metadataFactory := NewFactory(&Metadata{Source: "web"}).
+		Attr("Score", func(args Args) (interface{}, error) {
+			return 10, nil
+		})
+	var eventFactory = NewFactory(&Event{}).
+		SubFactoryJSON("Metadata", metadataFactory).
+		SubFactoryJSON("Raw", metadataFactory).
+		SubFactoryJSON("Text", metadataFactory)
+
+	event := eventFactory.MustCreate().(*Event)
+	expected := `{"source":"web","score":10}`
+	if string(event.Metadata) != expected || string(event.Raw) != expected || event.Text != expected {
+		t.Errorf("unexpected event: %s, %s, %s", event.Metadata, event.Raw, event.Text)
+	}
+
+	failing := NewFactory(&Metadata{}).WithJSONMarshalFunc("Source", func(v interface{}) (json.RawMessage, error) {
+		return nil, fmt.Errorf("failed")
+	})
+	if _, err := NewFactory(&Event{}).SubFactoryJSON("Text", failing).Create(); err == nil {
+		t.Error("a marshalling error should be returned")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SubFactoryJSON should panic")
+		}
+	}()
+	NewFactory(&struct{ Metadata map[string]interface{} }{}).SubFactoryJSON("Metadata", metadataFactory)
+}
@@ -0,0 +1,65 @@
+package factory
+
+import (
+	"sync"
+	"time"
+)
+
+// FactoryMetrics is statistics of object creation enabled by WithMetrics.
+type FactoryMetrics struct {
+	TotalCreated  int64
+	TotalDuration time.Duration
+	PerAttr       map[string]time.Duration // total duration of each generator.
+}
+
+type metricsRecorder struct {
+	mu      sync.Mutex
+	metrics FactoryMetrics
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{metrics: FactoryMetrics{PerAttr: make(map[string]time.Duration)}}
+}
+
+func (mr *metricsRecorder) addCreated(d time.Duration) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.metrics.TotalCreated++
+	mr.metrics.TotalDuration += d
+}
+
+func (mr *metricsRecorder) addAttr(name string, d time.Duration) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.metrics.PerAttr[name] += d
+}
+
+// WithMetrics enables to record metrics of object creation.
+func (fa *Factory) WithMetrics() *Factory {
+	fa.metrics = newMetricsRecorder()
+	return fa
+}
+
+// Metrics returns metrics recorded since WithMetrics or ResetMetrics is called.
+func (fa *Factory) Metrics() FactoryMetrics {
+	if fa.metrics == nil {
+		return FactoryMetrics{PerAttr: make(map[string]time.Duration)}
+	}
+	fa.metrics.mu.Lock()
+	defer fa.metrics.mu.Unlock()
+	m := fa.metrics.metrics
+	m.PerAttr = make(map[string]time.Duration, len(fa.metrics.metrics.PerAttr))
+	for k, v := range fa.metrics.metrics.PerAttr {
+		m.PerAttr[k] = v
+	}
+	return m
+}
+
+// ResetMetrics clears recorded metrics.
+func (fa *Factory) ResetMetrics() {
+	if fa.metrics != nil {
+		fa.metrics.mu.Lock()
+		defer fa.metrics.mu.Unlock()
+		fa.metrics.metrics = FactoryMetrics{PerAttr: make(map[string]time.Duration)}
+	}
+}
@@ -0,0 +1,47 @@
+package factory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFactoryMetrics(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			return "bluele", nil
+		})
+
+	userFactory.MustCreate()
+	if m := userFactory.Metrics(); m.TotalCreated != 0 {
+		t.Errorf("metrics should not be recorded without WithMetrics, but %v", m.TotalCreated)
+	}
+
+	userFactory.WithMetrics()
+	for i := 0; i < 3; i++ {
+		userFactory.MustCreate()
+	}
+	m := userFactory.Metrics()
+	if m.TotalCreated != 3 {
+		t.Errorf("m.TotalCreated should be 3, not %v", m.TotalCreated)
+	}
+	if m.PerAttr["Name"] < 3*time.Millisecond {
+		t.Errorf("m.PerAttr[\"Name\"] should be at least 3ms, not %v", m.PerAttr["Name"])
+	}
+	if m.TotalDuration < m.PerAttr["Name"] {
+		t.Errorf("m.TotalDuration should be at least %v, not %v", m.PerAttr["Name"], m.TotalDuration)
+	}
+
+	userFactory.ResetMetrics()
+	if m := userFactory.Metrics(); m.TotalCreated != 0 || len(m.PerAttr) != 0 {
+		t.Errorf("metrics should be reset, but %+v", m)
+	}
+}
@@ -0,0 +1,54 @@
+package factory
+
+import (
+	"sync"
+)
+
+// FactoryOption configures a factory created by NewFactory.
+// It is made only by the functions in this package, since it is applied before the model is parsed.
+type FactoryOption struct {
+	apply func(*Factory)
+}
+
+var (
+	defaultsMu     sync.RWMutex
+	defaultOptions []FactoryOption
+)
+
+// SetDefaults sets options applied to every factory created after the call, replacing the previous ones.
+// Options are applied before the model is parsed, and each factory can override them by its own methods.
+func SetDefaults(opts ...FactoryOption) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+	defaultOptions = append([]FactoryOption(nil), opts...)
+}
+
+func (fa *Factory) applyDefaults() {
+	fa.tagName = TagName
+	defaultsMu.RLock()
+	defer defaultsMu.RUnlock()
+	for _, opt := range defaultOptions {
+		opt.apply(fa)
+	}
+}
+
+// TagNameOption makes the factory read the struct tag with the name instead of TagName.
+func TagNameOption(name string) FactoryOption {
+	return FactoryOption{apply: func(fa *Factory) {
+		fa.tagName = name
+	}}
+}
+
+// SeedOption makes random values generated by the factory reproducible like WithSeed.
+func SeedOption(seed int64) FactoryOption {
+	return FactoryOption{apply: func(fa *Factory) {
+		fa.WithSeed(seed)
+	}}
+}
+
+// StrictWiringOption enables WithStrictWiring.
+func StrictWiringOption() FactoryOption {
+	return FactoryOption{apply: func(fa *Factory) {
+		fa.WithStrictWiring()
+	}}
+}
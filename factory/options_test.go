@@ -0,0 +1,44 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetDefaults(t *testing.T) {
+	type User struct {
+		Name  string `fixture:"name;default=bluele"`
+		Score int64
+	}
+
+	SetDefaults(TagNameOption("fixture"), SeedOption(1), StrictWiringOption())
+	defer SetDefaults()
+
+	newUserFactory := func() *Factory {
+		return NewFactory(&User{}).FromQuick("Score", reflect.TypeOf(int64(0)))
+	}
+	a, b := newUserFactory().MustCreate().(*User), newUserFactory().MustCreate().(*User)
+	if a.Name != "bluele" {
+		t.Errorf("user.Name should be bluele, not %v", a.Name)
+	}
+	if a.Score != b.Score {
+		t.Errorf("factories with same seed should generate same results: %v, %v", a, b)
+	}
+	if _, err := newUserFactory().CreateWithOption(map[string]interface{}{"name": "jun"}); err != nil {
+		t.Error(err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("strict wiring should be enabled")
+			}
+		}()
+		newUserFactory().FromQuick("Score", reflect.TypeOf(int64(0)))
+	}()
+
+	SetDefaults()
+	if user := NewFactory(&User{}).MustCreate().(*User); user.Name != "" {
+		t.Errorf("user.Name should be empty without defaults, not %v", user.Name)
+	}
+}
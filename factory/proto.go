@@ -0,0 +1,24 @@
+package factory
+
+import (
+	"strings"
+)
+
+// protoInternalFields are fields of messages generated by protoc-gen-go for internal use.
+var protoInternalFields = map[string]bool{
+	"state":         true,
+	"sizeCache":     true,
+	"unknownFields": true,
+}
+
+// WithProtoSupport makes the factory leave internal fields of protobuf messages untouched,
+// such as XXX_unrecognized or sizeCache, so that factories work for generated message types.
+func (fa *Factory) WithProtoSupport() *Factory {
+	for i := 0; i < fa.numField; i++ {
+		name := fa.rt.Field(i).Name
+		if strings.HasPrefix(name, "XXX_") || protoInternalFields[name] {
+			fa.attrGens[i].skip = true
+		}
+	}
+	return fa
+}
@@ -0,0 +1,50 @@
+package factory
+
+import (
+	"testing"
+)
+
+type isUserContact interface {
+	isUserContact()
+}
+
+type userContactEmail struct {
+	Email string
+}
+
+func (*userContactEmail) isUserContact() {}
+
+func TestFactoryWithProtoSupport(t *testing.T) {
+	// User mimics a message type generated by protoc-gen-go.
+	type User struct {
+		state         struct{ atomicMessageInfo *int }
+		sizeCache     int32
+		unknownFields []byte
+
+		Name    string
+		Age     int32
+		Contact isUserContact
+
+		XXX_NoUnkeyedLiteral struct{}
+		XXX_unrecognized     []byte
+		XXX_sizecache        int32
+	}
+
+	var userFactory = NewFactory(&User{}).
+		WithProtoSupport().
+		AutoFill().
+		Attr("Contact", func(args Args) (interface{}, error) {
+			return &userContactEmail{Email: "bluele@example.com"}, nil
+		})
+
+	user := userFactory.MustCreate().(*User)
+	if user.Name == "" || user.Age == 0 {
+		t.Errorf("user.Name and user.Age should be generated: %+v", user)
+	}
+	if c, ok := user.Contact.(*userContactEmail); !ok || c.Email != "bluele@example.com" {
+		t.Errorf("unexpected user.Contact: %+v", user.Contact)
+	}
+	if user.XXX_sizecache != 0 || user.XXX_unrecognized != nil {
+		t.Errorf("internal fields should not be generated: %+v", user)
+	}
+}
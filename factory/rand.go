@@ -0,0 +1,155 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing/quick"
+	"time"
+)
+
+var defaultRand = newLockedRand(time.Now().UnixNano())
+
+// lockedRand is a goroutine safe source of random values.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (lr *lockedRand) Float64() float64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Float64()
+}
+
+func (lr *lockedRand) Intn(n int) int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Intn(n)
+}
+
+func (lr *lockedRand) Int63n(n int64) int64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Int63n(n)
+}
+
+func (lr *lockedRand) Perm(n int) []int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Perm(n)
+}
+
+// QuickValue returns an arbitrary value of the type by testing/quick.
+func (lr *lockedRand) QuickValue(tp reflect.Type) (reflect.Value, bool) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return quick.Value(tp, lr.r)
+}
+
+// WithSeed makes random values generated by the factory reproducible.
+func (fa *Factory) WithSeed(seed int64) *Factory {
+	fa.rand = newLockedRand(seed)
+	return fa
+}
+
+func (fa *Factory) random() *lockedRand {
+	if fa.rand == nil {
+		return defaultRand
+	}
+	return fa.rand
+}
+
+// Bool sets true to the attribute with probability trueRatio.
+// trueRatio is clamped to [0, 1].
+func (fa *Factory) Bool(name string, trueRatio float64) *Factory {
+	if trueRatio < 0 {
+		trueRatio = 0
+	} else if trueRatio > 1 {
+		trueRatio = 1
+	}
+	idx := fa.checkIdx(name)
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		return args.factory().random().Float64() < trueRatio, nil
+	})
+	return fa
+}
+
+// TimeBetween sets a random time in [start, end) to the attribute.
+// The attribute should be time.Time or a type defined on time.Time.
+func (fa *Factory) TimeBetween(name string, start, end time.Time) *Factory {
+	if !start.Before(end) {
+		panic("start should be before end: " + name)
+	}
+	idx := fa.checkIdx(name)
+	ft := fa.rt.Field(idx).Type
+	if !timeType.ConvertibleTo(ft) {
+		panic("Attribute should be time.Time: " + name)
+	}
+	span := int64(end.Sub(start))
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		t := start.Add(time.Duration(args.factory().random().Int63n(span)))
+		return reflect.ValueOf(t).Convert(ft).Interface(), nil
+	})
+	return fa
+}
+
+// uniqueState holds integers handed out by UniqueIntRange.
+type uniqueState struct {
+	mu   sync.Mutex
+	perm []int
+	used int
+}
+
+// UniqueIntRange sets an integer in [min, max) to the attribute, which is different from
+// any integers set before. The integers are handed out in random order, and creation fails
+// after all of them are used.
+func (fa *Factory) UniqueIntRange(name string, min, max int) *Factory {
+	if min >= max {
+		panic("min should be less than max: " + name)
+	}
+	idx := fa.checkIdx(name)
+	tp := fa.rt.Field(idx).Type
+	if numericKind(tp) == reflect.Invalid {
+		panic("Attribute should be numeric: " + name)
+	}
+	fa.attrGens[idx].unique = &uniqueState{}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		us := args.pipeline().seqs.unique(args.factory().attrGens[idx].unique)
+		us.mu.Lock()
+		defer us.mu.Unlock()
+		if us.perm == nil {
+			us.perm = args.factory().random().Perm(max - min)
+		}
+		if us.used >= len(us.perm) {
+			return nil, fmt.Errorf("unique integers of %v in [%v, %v) are exhausted", name, min, max)
+		}
+		n := min + us.perm[us.used]
+		us.used++
+		return reflect.ValueOf(n).Convert(tp).Interface(), nil
+	})
+	return fa
+}
+
+// FromQuick sets an arbitrary value of typ generated by testing/quick to the attribute.
+// If typ implements quick.Generator, its Generate method is used.
+func (fa *Factory) FromQuick(name string, typ reflect.Type) *Factory {
+	idx := fa.checkIdx(name)
+	if !typ.AssignableTo(fa.rt.Field(idx).Type) {
+		panic(typ.String() + " is not assignable to " + name)
+	}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		v, ok := args.factory().random().QuickValue(typ)
+		if !ok {
+			return nil, errors.New("cannot generate a value of " + typ.String())
+		}
+		return v.Interface(), nil
+	})
+	return fa
+}
@@ -0,0 +1,350 @@
+package factory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFactorySubFactoryOptional(t *testing.T) {
+	type Coupon struct {
+		Code string
+	}
+	type Order struct {
+		ID     int
+		Coupon *Coupon
+	}
+
+	var couponFactory = NewFactory(&Coupon{Code: "SALE"})
+	newOrderFactory := func(prob float64) *Factory {
+		return NewFactory(&Order{}).
+			SubFactoryOptional("Coupon", couponFactory, prob).
+			WithSeed(42)
+	}
+
+	var withCoupon int
+	orderFactory := newOrderFactory(0.3)
+	for i := 0; i < 1000; i++ {
+		if orderFactory.MustCreate().(*Order).Coupon != nil {
+			withCoupon++
+		}
+	}
+	if withCoupon < 200 || withCoupon > 400 {
+		t.Errorf("about 300 orders should have a coupon, but %v orders have", withCoupon)
+	}
+
+	// same seed generates same results.
+	a, b := newOrderFactory(0.5), newOrderFactory(0.5)
+	for i := 0; i < 100; i++ {
+		ca := a.MustCreate().(*Order).Coupon != nil
+		cb := b.MustCreate().(*Order).Coupon != nil
+		if ca != cb {
+			t.Fatal("factories with same seed should generate same results")
+		}
+	}
+
+	if order := newOrderFactory(0).MustCreate().(*Order); order.Coupon != nil {
+		t.Error("order.Coupon should be nil")
+	}
+	if order := newOrderFactory(1).MustCreate().(*Order); order.Coupon == nil {
+		t.Error("order.Coupon should not be nil")
+	}
+}
+
+func TestFactorySubFactoryOptionalWithNonPointer(t *testing.T) {
+	type Coupon struct {
+		Code string
+	}
+	type Order struct {
+		Coupon Coupon
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SubFactoryOptional should panic")
+		}
+	}()
+	NewFactory(&Order{}).SubFactoryOptional("Coupon", NewFactory(&Coupon{}), 0.5)
+}
+
+func TestFactorySubFactoryWeighted(t *testing.T) {
+	type Profile struct {
+		Kind string
+	}
+	type User struct {
+		Profile *Profile
+	}
+
+	var (
+		freeFactory    = NewFactory(&Profile{Kind: "free"})
+		premiumFactory = NewFactory(&Profile{Kind: "premium"})
+		neverFactory   = NewFactory(&Profile{Kind: "never"})
+	)
+
+	var userFactory = NewFactory(&User{}).
+		SubFactoryWeighted("Profile", []WeightedFactory{
+			{Factory: freeFactory, Weight: 3},
+			{Factory: premiumFactory, Weight: 1},
+			{Factory: neverFactory, Weight: 0},
+		}).
+		WithSeed(1)
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[userFactory.MustCreate().(*User).Profile.Kind]++
+	}
+	if counts["never"] != 0 {
+		t.Errorf("a factory with zero weight should not be chosen, but chosen %v times", counts["never"])
+	}
+	if counts["free"] < 650 || counts["free"] > 850 {
+		t.Errorf("about 750 users should be free, but %v users are", counts["free"])
+	}
+}
+
+func TestFactorySubFactoryWeightedWithInvalidChoices(t *testing.T) {
+	type Group struct{}
+	type Profile struct{}
+	type User struct {
+		Profile *Profile
+	}
+
+	for name, choices := range map[string][]WeightedFactory{
+		"empty":        nil,
+		"zero weight":  {{Factory: NewFactory(&Profile{}), Weight: 0}},
+		"unassignable": {{Factory: NewFactory(&Group{}), Weight: 1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("SubFactoryWeighted should panic")
+				}
+			}()
+			NewFactory(&User{}).SubFactoryWeighted("Profile", choices)
+		})
+	}
+}
+
+func TestFactoryBool(t *testing.T) {
+	type User struct {
+		IsActive bool
+		IsAdmin  bool
+		IsBanned bool
+	}
+
+	var userFactory = NewFactory(&User{}).
+		Bool("IsActive", 0.8).
+		Bool("IsAdmin", 2).
+		Bool("IsBanned", -1).
+		WithSeed(1)
+
+	var active int
+	for i := 0; i < 1000; i++ {
+		user := userFactory.MustCreate().(*User)
+		if user.IsActive {
+			active++
+		}
+		if !user.IsAdmin {
+			t.Fatal("user.IsAdmin should be always true")
+		}
+		if user.IsBanned {
+			t.Fatal("user.IsBanned should be always false")
+		}
+	}
+	if active < 700 || active > 900 {
+		t.Errorf("about 800 users should be active, but %v users are", active)
+	}
+}
+
+func TestFactoryFromQuick(t *testing.T) {
+	type User struct {
+		Name  string
+		Score int64
+		Tags  []string
+		Ch    chan int
+	}
+
+	newUserFactory := func() *Factory {
+		return NewFactory(&User{}).
+			FromQuick("Name", reflect.TypeOf("")).
+			FromQuick("Score", reflect.TypeOf(int64(0))).
+			FromQuick("Tags", reflect.TypeOf([]string{})).
+			WithSeed(1)
+	}
+
+	a := newUserFactory().MustCreate().(*User)
+	b := newUserFactory().MustCreate().(*User)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("factories with same seed should generate same results: %v, %v", a, b)
+	}
+
+	chFactory := NewFactory(&User{}).FromQuick("Ch", reflect.TypeOf(make(chan int)))
+	if _, err := chFactory.Create(); err == nil {
+		t.Error("an unsupported type should return an error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("FromQuick should panic")
+		}
+	}()
+	NewFactory(&User{}).FromQuick("Name", reflect.TypeOf(0))
+}
+
+func TestFactoryTimeBetween(t *testing.T) {
+	type Timestamp time.Time
+	type Post struct {
+		CreatedAt time.Time
+		UpdatedAt Timestamp
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	var postFactory = NewFactory(&Post{}).
+		TimeBetween("CreatedAt", start, end).
+		TimeBetween("UpdatedAt", start, end)
+
+	for i := 0; i < 100; i++ {
+		post := postFactory.MustCreate().(*Post)
+		for _, tm := range []time.Time{post.CreatedAt, time.Time(post.UpdatedAt)} {
+			if tm.Before(start) || !tm.Before(end) {
+				t.Fatalf("%v should be in [%v, %v)", tm, start, end)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("TimeBetween should panic")
+		}
+	}()
+	NewFactory(&Post{}).TimeBetween("CreatedAt", end, start)
+}
+
+func TestFactorySharedPool(t *testing.T) {
+	type Customer struct {
+		ID int
+	}
+	type Order struct {
+		Customer *Customer
+	}
+
+	var customerFactory = NewFactory(&Customer{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		})
+	var orderFactory = NewFactory(&Order{}).
+		SharedPool("Customer", 3, customerFactory).
+		WithSeed(1)
+
+	customers := make(map[*Customer]bool)
+	for i := 0; i < 100; i++ {
+		customers[orderFactory.MustCreate().(*Order).Customer] = true
+	}
+	if len(customers) != 3 {
+		t.Errorf("orders should share 3 customers, not %v", len(customers))
+	}
+	if c := customerFactory.MustCreate().(*Customer); c.ID != 4 {
+		t.Errorf("pool should be created only once, but next customer.ID is %v", c.ID)
+	}
+
+	type Shop struct {
+		Name     string
+		Customer *Customer
+	}
+	var shopFactory = NewFactory(&Shop{}).
+		SharedPool("Customer", 1, NewFactory(&Customer{}))
+	ctx := WithOverrides(context.Background(), map[string]interface{}{"ID": 10, "Name": "shop"})
+	shop := shopFactory.MustCreateWithContextAndOption(ctx, nil).(*Shop)
+	if shop.Name != "shop" || shop.Customer.ID != 0 {
+		t.Errorf("overrides should be applied only to the root object: %+v %+v", shop, shop.Customer)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SharedPool should panic")
+		}
+	}()
+	NewFactory(&struct{ Customer Customer }{}).SharedPool("Customer", 3, customerFactory)
+}
+
+func TestFactorySharedPoolOfUnassignableModel(t *testing.T) {
+	type Customer struct {
+		ID int
+	}
+	type Shop struct {
+		ID int
+	}
+	type Order struct {
+		Customer *Customer
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SharedPool should panic")
+		}
+	}()
+	NewFactory(&Order{}).SharedPool("Customer", 3, NewFactory(&Shop{}))
+}
+
+func TestFactoryUniqueIntRange(t *testing.T) {
+	type Seat struct {
+		Number uint8
+	}
+
+	newSeatFactory := func() *Factory {
+		return NewFactory(&Seat{}).UniqueIntRange("Number", 10, 20).WithSeed(3)
+	}
+
+	a, b := newSeatFactory(), newSeatFactory()
+	seen := make(map[uint8]bool)
+	for i := 0; i < 10; i++ {
+		sa, sb := a.MustCreate().(*Seat), b.MustCreate().(*Seat)
+		if *sa != *sb {
+			t.Fatalf("factories with same seed should generate same results: %v, %v", sa, sb)
+		}
+		if sa.Number < 10 || sa.Number >= 20 || seen[sa.Number] {
+			t.Fatalf("unexpected seat number: %v", sa.Number)
+		}
+		seen[sa.Number] = true
+	}
+
+	if _, err := a.Create(); err == nil {
+		t.Error("exhausted range should return an error")
+	}
+}
+
+func TestSubSliceFactoryWeightedSize(t *testing.T) {
+	type Item struct {
+		Name string
+	}
+	type Order struct {
+		Items []*Item
+	}
+
+	newOrderFactory := func() *Factory {
+		return NewFactory(&Order{}).
+			SubSliceFactoryWeightedSize("Items", NewFactory(&Item{}), map[int]int{0: 3, 2: 6, 10: 1}).
+			WithSeed(5)
+	}
+
+	a, b := newOrderFactory(), newOrderFactory()
+	counts := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		la, lb := len(a.MustCreate().(*Order).Items), len(b.MustCreate().(*Order).Items)
+		if la != lb {
+			t.Fatalf("factories with same seed should generate same results: %v, %v", la, lb)
+		}
+		counts[la]++
+	}
+	if len(counts) != 3 || counts[2] < 500 || counts[10] > 200 {
+		t.Errorf("unexpected distribution of sizes: %v", counts)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SubSliceFactoryWeightedSize should panic")
+		}
+	}()
+	NewFactory(&Order{}).SubSliceFactoryWeightedSize("Items", NewFactory(&Item{}), map[int]int{})
+}
@@ -0,0 +1,29 @@
+package factory
+
+import (
+	"sync"
+)
+
+var (
+	generatorsMu sync.RWMutex
+	generators   = make(map[string]func(Args) (interface{}, error))
+)
+
+// RegisterGenerator registers a generator with the name, which can be set to attributes by AttrNamed.
+// Registering with the same name replaces the previous generator.
+func RegisterGenerator(name string, gen func(Args) (interface{}, error)) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	generators[name] = gen
+}
+
+// AttrNamed sets the generator registered by RegisterGenerator with generatorName to the attribute.
+func (fa *Factory) AttrNamed(name, generatorName string) *Factory {
+	generatorsMu.RLock()
+	gen, ok := generators[generatorName]
+	generatorsMu.RUnlock()
+	if !ok {
+		panic("No such generator: " + generatorName)
+	}
+	return fa.Attr(name, gen)
+}
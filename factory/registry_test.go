@@ -0,0 +1,27 @@
+package factory
+
+import (
+	"testing"
+)
+
+func TestFactoryAttrNamed(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	RegisterGenerator("test.name", func(args Args) (interface{}, error) {
+		return "bluele", nil
+	})
+
+	user := NewFactory(&User{}).AttrNamed("Name", "test.name").MustCreate().(*User)
+	if user.Name != "bluele" {
+		t.Errorf("user.Name should be bluele, not %v", user.Name)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("AttrNamed should panic")
+		}
+	}()
+	NewFactory(&User{}).AttrNamed("Name", "test.unknown")
+}
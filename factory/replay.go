@@ -0,0 +1,68 @@
+package factory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+type replayLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (rl *replayLog) write(trace map[string]interface{}) error {
+	line, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	_, err = rl.w.Write(append(line, '\n'))
+	return err
+}
+
+// WithReplayLog writes a JSON line to w for each object created by the factory.
+// The line holds the values of all exported attributes like the option returned by CreateWithTrace,
+// so that Replay can create the same objects again. Sub objects are included in the line of the root object.
+func (fa *Factory) WithReplayLog(w io.Writer) *Factory {
+	fa.replayLog = &replayLog{w: w}
+	return fa
+}
+
+// Replay creates objects again from the lines written by WithReplayLog.
+func (fa *Factory) Replay(r io.Reader) ([]interface{}, error) {
+	var insts []interface{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			return nil, fmt.Errorf("invalid replay log at line %v: %v", n, err)
+		}
+		opt := make(map[string]interface{}, len(fields))
+		for name, raw := range fields {
+			idx, ok := fa.nameIndexMap[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid replay log at line %v: no such attribute name: %v", n, name)
+			}
+			v := reflect.New(fa.rt.Field(idx).Type)
+			if err := json.Unmarshal(raw, v.Interface()); err != nil {
+				return nil, fmt.Errorf("invalid replay log at line %v: %v: %v", n, name, err)
+			}
+			opt[name] = v.Elem().Interface()
+		}
+		inst, err := fa.CreateWithOption(opt)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return insts, nil
+}
@@ -0,0 +1,49 @@
+package factory
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFactoryReplay(t *testing.T) {
+	type Group struct {
+		Name string
+	}
+	type User struct {
+		ID    int
+		Score float64
+		Tags  []string
+		Group *Group
+	}
+
+	var log bytes.Buffer
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		FromQuick("Score", reflect.TypeOf(float64(0))).
+		SubFactory("Group", NewFactory(&Group{}).FromQuick("Name", reflect.TypeOf(""))).
+		WithReplayLog(&log)
+
+	users, err := userFactory.CreateBatchWithOption(3, map[string]interface{}{"Tags": []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(log.String(), "\n"); lines != 3 {
+		t.Fatalf("log should have 3 lines, not %v", lines)
+	}
+
+	replayed, err := NewFactory(&User{}).Replay(strings.NewReader(log.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(users, replayed) {
+		t.Errorf("replayed users should be %v, not %v", users, replayed)
+	}
+
+	if _, err := NewFactory(&User{}).Replay(strings.NewReader(`{"Unknown":1}`)); err == nil {
+		t.Error("an unknown attribute should return an error")
+	}
+}
@@ -0,0 +1,44 @@
+package factory
+
+import (
+	"reflect"
+)
+
+// NewScalarFactory returns a new factory for a model which isn't a struct.
+// e.g. `type IDList []int`
+// Create returns the value generated by gen, which is converted to the model type.
+func NewScalarFactory(model interface{}, gen func(Args) (interface{}, error)) *Factory {
+	fa := &Factory{}
+	fa.model = model
+	fa.nameIndexMap = make(map[string]int)
+	fa.scalarGen = gen
+	fa.applyDefaults()
+
+	rt := reflect.TypeOf(model)
+	rv := reflect.ValueOf(model)
+	fa.isPtr = rt.Kind() == reflect.Ptr
+	if fa.isPtr {
+		rt = rt.Elem()
+		rv = rv.Elem()
+	}
+	fa.rt = rt
+	fa.rv = &rv
+	return fa
+}
+
+func (fa *Factory) buildScalar(inst *reflect.Value, args Args) error {
+	inst.Set(*fa.rv)
+	v, err := fa.scalarGen(args)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	rv, err := convertValue(v, fa.rt)
+	if err != nil {
+		return err
+	}
+	inst.Set(rv)
+	return nil
+}
@@ -0,0 +1,63 @@
+package factory
+
+import (
+	"testing"
+)
+
+func TestScalarFactory(t *testing.T) {
+	type IDList []int
+
+	var seq int
+	var idListFactory = NewScalarFactory(IDList{}, func(args Args) (interface{}, error) {
+		seq++
+		return []int{seq, seq + 1}, nil
+	})
+
+	ids, ok := idListFactory.MustCreate().(IDList)
+	if !ok {
+		t.Fatal("It should be IDList type.")
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("ids should be [1 2], not %v", ids)
+	}
+
+	var namePtrFactory = NewScalarFactory(new(string), func(args Args) (interface{}, error) {
+		return "bluele", nil
+	})
+	name, ok := namePtrFactory.MustCreate().(*string)
+	if !ok {
+		t.Fatal("It should be *string type.")
+	}
+	if *name != "bluele" {
+		t.Errorf("name should be bluele, not %v", *name)
+	}
+
+	var brokenFactory = NewScalarFactory(IDList{}, func(args Args) (interface{}, error) {
+		return "bluele", nil
+	})
+	if _, err := brokenFactory.Create(); err == nil {
+		t.Error("an unassignable value should return an error")
+	}
+
+	var codeFactory = NewScalarFactory("", func(args Args) (interface{}, error) {
+		return 65, nil
+	})
+	if code, err := codeFactory.Create(); err == nil {
+		t.Errorf("an int should not be converted to a string, but got %q", code)
+	}
+	var countFactory = NewScalarFactory(0, func(args Args) (interface{}, error) {
+		return 1.5, nil
+	})
+	if count, err := countFactory.Create(); err == nil {
+		t.Errorf("a float64 should not be truncated to an int, but got %v", count)
+	}
+}
+
+func TestFactoryWithNonStructModel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewFactory should panic")
+		}
+	}()
+	NewFactory([]int{})
+}
@@ -0,0 +1,15 @@
+package factory
+
+import (
+	"testing"
+)
+
+// Scoped returns a clone of the factory for the test, whose sequences start from the first value
+// and unique ranges hand out all integers again, so that counters aren't shared among tests.
+// Sequences of sub factories and AutoFill also start over while they create objects for the clone.
+// The clone is reset again when the test finishes.
+func (fa *Factory) Scoped(t testing.TB) *Factory {
+	nfa := fa.withFreshSequences()
+	t.Cleanup(nfa.seqs.reset)
+	return nfa
+}
@@ -0,0 +1,74 @@
+package factory
+
+import (
+	"testing"
+)
+
+func TestFactoryScoped(t *testing.T) {
+	type User struct {
+		ID   int
+		Seat int
+	}
+
+	var userFactory = NewFactory(&User{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		UniqueIntRange("Seat", 0, 2)
+	userFactory.MustCreate()
+
+	var scoped *Factory
+	for i := 0; i < 2; i++ {
+		t.Run("scoped", func(t *testing.T) {
+			scoped = userFactory.Scoped(t)
+			user := scoped.MustCreate().(*User)
+			scoped.MustCreate()
+			if user.ID != 1 {
+				t.Errorf("scoped factory should start from the first value: %+v", user)
+			}
+			if _, err := scoped.Create(); err == nil {
+				t.Error("unique range of the scoped factory should be exhausted")
+			}
+		})
+	}
+	if state := scoped.SequenceState(); state["ID"] != 0 {
+		t.Errorf("scoped factory should be reset after the test: %v", state)
+	}
+
+	if user := userFactory.MustCreate().(*User); user.ID != 2 {
+		t.Errorf("original factory should not be affected, but user.ID is %v", user.ID)
+	}
+}
+
+func TestFactoryScopedSubFactory(t *testing.T) {
+	type Comment struct {
+		ID   int
+		Body string
+	}
+	type Post struct {
+		ID       int
+		Comments []*Comment
+	}
+
+	var commentFactory = NewFactory(&Comment{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		AutoFill()
+	var postFactory = NewFactory(&Post{}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		SubSliceFactory("Comments", commentFactory, func() int { return 1 })
+	postFactory.MustCreate()
+	postFactory.MustCreate()
+
+	for i := 0; i < 2; i++ {
+		t.Run("scoped", func(t *testing.T) {
+			post := postFactory.Scoped(t).MustCreate().(*Post)
+			if post.ID != 1 || post.Comments[0].ID != 1 || post.Comments[0].Body != "Body-1" {
+				t.Errorf("sub factories of the scoped factory should start from the first value: %+v %+v", post, post.Comments[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package factory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// FromChan sets a value received from ch to the attribute on each creation.
+// When ch is closed, the attribute keeps its default value if fallback is true, otherwise creation is failed.
+// Receiving is canceled when the context of creation is done.
+func (fa *Factory) FromChan(name string, ch <-chan interface{}, fallback bool) *Factory {
+	idx := fa.checkIdx(name)
+	ag := fa.attrGens[idx]
+	tp := fa.rt.Field(idx).Type
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				if fallback {
+					return ag.defaultValue(), nil
+				}
+				return nil, fmt.Errorf("channel for %v is closed", name)
+			}
+			if vt := reflect.TypeOf(v); vt == nil || !vt.AssignableTo(tp) {
+				return nil, fmt.Errorf("%v from channel is not assignable to %v", vt, name)
+			}
+			return v, nil
+		case <-args.Context().Done():
+			return nil, args.Context().Err()
+		}
+	})
+	return fa
+}
+
+// FromQuery sets one of the results of query, chosen randomly, to the attribute on each creation.
+// The query runs once with the context of the first creation, and its results are reused afterwards.
+// When the results are empty, the attribute keeps its default value if fallback is true, otherwise creation is failed.
+func (fa *Factory) FromQuery(name string, query func(ctx context.Context) ([]interface{}, error), fallback bool) *Factory {
+	idx := fa.checkIdx(name)
+	ag := fa.attrGens[idx]
+	tp := fa.rt.Field(idx).Type
+	var mu sync.Mutex
+	var results []interface{}
+	var done bool
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !done {
+			rs, err := query(args.Context())
+			if err != nil {
+				return nil, fmt.Errorf("query for %v failed: %v", name, err)
+			}
+			results, done = rs, true
+		}
+		if len(results) == 0 {
+			if fallback {
+				return ag.defaultValue(), nil
+			}
+			return nil, fmt.Errorf("query for %v returned no results", name)
+		}
+		v := results[args.factory().random().Intn(len(results))]
+		if vt := reflect.TypeOf(v); vt == nil || !vt.AssignableTo(tp) {
+			return nil, fmt.Errorf("%v from query is not assignable to %v", vt, name)
+		}
+		return v, nil
+	})
+	return fa
+}
+
+// FromLines sets a value parsed from the next line of r to the attribute on each creation.
+// When r reaches EOF, lines are read again from the first one if cycle is true, otherwise creation is failed.
+func (fa *Factory) FromLines(name string, r io.Reader, parse func(string) (interface{}, error), cycle bool) *Factory {
+	idx := fa.checkIdx(name)
+	src := &lineSource{scanner: bufio.NewScanner(r), cycle: cycle}
+	fa.setGen(idx, func(args Args) (interface{}, error) {
+		line, err := src.next()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read a line for %v: %v", name, err)
+		}
+		v, err := parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q for %v: %v", line, name, err)
+		}
+		return v, nil
+	})
+	return fa
+}
+
+// lineSource reads lines and keeps them to read again after EOF.
+type lineSource struct {
+	mu      sync.Mutex
+	scanner *bufio.Scanner
+	cycle   bool
+	eof     bool
+	lines   []string
+	pos     int
+}
+
+func (ls *lineSource) next() (string, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if !ls.eof {
+		if ls.scanner.Scan() {
+			line := ls.scanner.Text()
+			if ls.cycle {
+				ls.lines = append(ls.lines, line)
+			}
+			return line, nil
+		}
+		if err := ls.scanner.Err(); err != nil {
+			return "", err
+		}
+		ls.eof = true
+	}
+	if len(ls.lines) == 0 {
+		return "", io.EOF
+	}
+	line := ls.lines[ls.pos%len(ls.lines)]
+	ls.pos++
+	return line, nil
+}
@@ -0,0 +1,109 @@
+package factory
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFactoryFromChan(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	ch := make(chan interface{}, 2)
+	ch <- "bluele"
+	ch <- "jun"
+	close(ch)
+
+	var userFactory = NewFactory(&User{Name: "anonymous"}).FromChan("Name", ch, true)
+	for _, name := range []string{"bluele", "jun", "anonymous"} {
+		if user := userFactory.MustCreate().(*User); user.Name != name {
+			t.Errorf("user.Name should be %v, not %v", name, user.Name)
+		}
+	}
+
+	closed := make(chan interface{})
+	close(closed)
+	if _, err := NewFactory(&User{}).FromChan("Name", closed, false).Create(); err == nil {
+		t.Error("a closed channel should return an error")
+	}
+
+	invalid := make(chan interface{}, 1)
+	invalid <- 1
+	if _, err := NewFactory(&User{}).FromChan("Name", invalid, false).Create(); err == nil {
+		t.Error("an unassignable value should return an error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := NewFactory(&User{}).FromChan("Name", make(chan interface{}), false).CreateWithContext(ctx); err == nil {
+		t.Error("receiving should be canceled by the context")
+	}
+}
+
+func TestFactoryFromLines(t *testing.T) {
+	type User struct {
+		Age int
+	}
+
+	parse := func(line string) (interface{}, error) {
+		return strconv.Atoi(line)
+	}
+
+	userFactory := NewFactory(&User{}).FromLines("Age", strings.NewReader("20\n30\n"), parse, true)
+	for _, age := range []int{20, 30, 20, 30} {
+		if user := userFactory.MustCreate().(*User); user.Age != age {
+			t.Errorf("user.Age should be %v, not %v", age, user.Age)
+		}
+	}
+
+	userFactory = NewFactory(&User{}).FromLines("Age", strings.NewReader("20\n"), parse, false)
+	userFactory.MustCreate()
+	if _, err := userFactory.Create(); err == nil {
+		t.Error("EOF should return an error")
+	}
+
+	userFactory = NewFactory(&User{}).FromLines("Age", strings.NewReader("twenty\n"), parse, false)
+	if _, err := userFactory.Create(); err == nil || !strings.Contains(err.Error(), `"twenty"`) {
+		t.Errorf("a parse error should contain the line, but got %v", err)
+	}
+}
+
+func TestFactoryFromQuery(t *testing.T) {
+	type Customer struct {
+		ID int
+	}
+	type Order struct {
+		Customer *Customer
+	}
+
+	existing := []interface{}{&Customer{ID: 1}, &Customer{ID: 2}}
+	var calls int
+	query := func(ctx context.Context) ([]interface{}, error) {
+		calls++
+		return existing, nil
+	}
+	orderFactory := NewFactory(&Order{}).FromQuery("Customer", query, false)
+	for i := 0; i < 10; i++ {
+		order := orderFactory.MustCreate().(*Order)
+		if order.Customer != existing[0] && order.Customer != existing[1] {
+			t.Errorf("order.Customer should be one of the results, not %v", order.Customer)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("query should run once, but ran %v times", calls)
+	}
+
+	empty := func(ctx context.Context) ([]interface{}, error) {
+		return nil, nil
+	}
+	if _, err := NewFactory(&Order{}).FromQuery("Customer", empty, false).Create(); err == nil {
+		t.Error("empty results should return an error")
+	}
+	if order := NewFactory(&Order{}).FromQuery("Customer", empty, true).MustCreate().(*Order); order.Customer != nil {
+		t.Errorf("order.Customer should be nil, not %v", order.Customer)
+	}
+}
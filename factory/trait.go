@@ -0,0 +1,67 @@
+package factory
+
+import (
+	"context"
+	"errors"
+)
+
+type trait struct {
+	apply func(*Factory)
+	hook  func(Args) error
+}
+
+// Trait registers a named set of changes to the factory, which is applied by CreateWithTraits.
+func (fa *Factory) Trait(name string, apply func(*Factory)) *Factory {
+	return fa.TraitWithHook(name, apply, nil)
+}
+
+// TraitWithHook is like Trait, but hook is invoked after the OnCreate callback
+// when the trait is applied. Hooks are invoked in the order traits are applied.
+func (fa *Factory) TraitWithHook(name string, apply func(*Factory), hook func(Args) error) *Factory {
+	if fa.traits == nil {
+		fa.traits = make(map[string]*trait)
+	}
+	fa.traits[name] = &trait{apply: apply, hook: hook}
+	return fa
+}
+
+// CreateWithTraits creates a new object with option by a clone of the factory
+// to which the traits are applied in order.
+func (fa *Factory) CreateWithTraits(opt map[string]interface{}, traits ...string) (interface{}, error) {
+	nfa, err := fa.withTraits(traits...)
+	if err != nil {
+		return nil, err
+	}
+	return nfa.create(context.Background(), opt, nil)
+}
+
+// CreateWithRandomTraits is like CreateWithTraits, but each of the traits is
+// applied with probability 0.5. The selection is reproducible by WithSeed.
+func (fa *Factory) CreateWithRandomTraits(traits ...string) (interface{}, error) {
+	var selected []string
+	for _, name := range traits {
+		if fa.random().Float64() < 0.5 {
+			selected = append(selected, name)
+		}
+	}
+	return fa.CreateWithTraits(nil, selected...)
+}
+
+func (fa *Factory) withTraits(names ...string) (*Factory, error) {
+	nfa := fa.Clone()
+	nfa.strictWiring = false
+	for _, name := range names {
+		tr, ok := fa.traits[name]
+		if !ok {
+			return nil, errors.New("No such trait name: " + name)
+		}
+		if tr.apply != nil {
+			tr.apply(nfa)
+		}
+		if tr.hook != nil {
+			nfa.traitHooks = append(nfa.traitHooks, tr.hook)
+		}
+	}
+	nfa.strictWiring = fa.strictWiring
+	return nfa, nil
+}
@@ -0,0 +1,144 @@
+package factory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFactoryCreateWithTraits(t *testing.T) {
+	type User struct {
+		ID      int
+		Name    string
+		Role    string
+		IsAdmin bool
+	}
+
+	var calls []string
+	var userFactory = NewFactory(&User{Role: "member"}).
+		SeqInt("ID", func(n int) (interface{}, error) {
+			return n, nil
+		}).
+		Attr("Name", func(args Args) (interface{}, error) {
+			return "bluele", nil
+		}).
+		OnCreate(func(args Args) error {
+			calls = append(calls, "base")
+			return nil
+		}).
+		TraitWithHook("admin", func(fa *Factory) {
+			fa.Attr("Role", func(args Args) (interface{}, error) {
+				return "admin", nil
+			})
+		}, func(args Args) error {
+			args.Instance().(*User).IsAdmin = true
+			calls = append(calls, "admin")
+			return nil
+		}).
+		TraitWithHook("audited", nil, func(args Args) error {
+			calls = append(calls, "audited")
+			return nil
+		}).
+		TraitWithHook("broken", nil, func(args Args) error {
+			return errors.New("failed")
+		}).
+		Trait("anonymous", func(fa *Factory) {
+			fa.Attr("Name", func(args Args) (interface{}, error) {
+				return "anonymous", nil
+			})
+		})
+
+	inst, err := userFactory.CreateWithTraits(nil, "admin", "audited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := inst.(*User)
+	if user.Role != "admin" || !user.IsAdmin || user.Name != "bluele" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	if !reflect.DeepEqual(calls, []string{"base", "admin", "audited"}) {
+		t.Errorf("hooks should be called in order, but %v", calls)
+	}
+
+	calls = nil
+	user = userFactory.MustCreate().(*User)
+	if user.Role != "member" || user.IsAdmin {
+		t.Errorf("traits should not change the factory: %+v", user)
+	}
+	if !reflect.DeepEqual(calls, []string{"base"}) {
+		t.Errorf("hooks of traits should not be called, but %v", calls)
+	}
+
+	if inst, err := userFactory.CreateWithTraits(map[string]interface{}{"ID": 100}, "anonymous"); err != nil {
+		t.Error(err)
+	} else if user := inst.(*User); user.Name != "anonymous" || user.ID != 100 {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	if _, err := userFactory.CreateWithTraits(nil, "broken"); err == nil {
+		t.Error("an error of trait hook should abort creation")
+	}
+	if _, err := userFactory.CreateWithTraits(nil, "unknown"); err == nil {
+		t.Error("an unknown trait should return an error")
+	}
+}
+
+func TestFactoryCloneTraits(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	var baseFactory = NewFactory(&User{}).
+		Trait("admin", func(fa *Factory) {})
+	var clone = baseFactory.Clone().
+		Trait("guest", func(fa *Factory) {})
+
+	if _, err := baseFactory.CreateWithTraits(nil, "guest"); err == nil {
+		t.Error("a trait registered on the clone should not be registered on the base factory")
+	}
+	if _, err := clone.CreateWithTraits(nil, "admin", "guest"); err != nil {
+		t.Errorf("the clone should have traits of the base factory: %v", err)
+	}
+}
+
+func TestFactoryCreateWithRandomTraits(t *testing.T) {
+	type User struct {
+		Role   string
+		Locale string
+	}
+
+	newUserFactory := func() *Factory {
+		return NewFactory(&User{Role: "member", Locale: "en"}).
+			Trait("admin", func(fa *Factory) {
+				fa.Attr("Role", func(args Args) (interface{}, error) {
+					return "admin", nil
+				})
+			}).
+			Trait("japanese", func(fa *Factory) {
+				fa.Attr("Locale", func(args Args) (interface{}, error) {
+					return "ja", nil
+				})
+			}).
+			WithSeed(7)
+	}
+
+	a, b := newUserFactory(), newUserFactory()
+	seen := make(map[User]bool)
+	for i := 0; i < 100; i++ {
+		ua, err := a.CreateWithRandomTraits("admin", "japanese")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ub, err := b.CreateWithRandomTraits("admin", "japanese")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ua, ub) {
+			t.Fatalf("factories with same seed should generate same results: %v, %v", ua, ub)
+		}
+		seen[*ua.(*User)] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("all combinations of traits should be generated, but got %v", seen)
+	}
+}
@@ -1,18 +1,111 @@
 package factory
 
 import (
+	"errors"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
 func getAttrName(sf reflect.StructField, tagName string) string {
-	tag := sf.Tag.Get(tagName)
-	if tag != "" {
-		return tag
+	name, _ := parseTag(sf.Tag.Get(tagName))
+	if name != "" {
+		return name
 	}
 	return sf.Name
 }
 
+// getAttrOptions returns the options following the attribute name in a tag.
+// e.g. `factory:"name;default=active"` returns {"default": "active"}
+func getAttrOptions(sf reflect.StructField, tagName string) map[string]string {
+	_, opts := parseTag(sf.Tag.Get(tagName))
+	return opts
+}
+
+func parseTag(tag string) (string, map[string]string) {
+	parts := strings.Split(tag, ";")
+	opts := make(map[string]string)
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			opts[strings.TrimSpace(kv[0])] = kv[1]
+		} else {
+			opts[strings.TrimSpace(kv[0])] = ""
+		}
+	}
+	return strings.TrimSpace(parts[0]), opts
+}
+
+// convertString converts a string into a value of the given type.
+// Supported kinds are string, bool, integers and floats.
+func convertString(s string, tp reflect.Type) (reflect.Value, error) {
+	rv := reflect.New(tp).Elem()
+	switch tp.Kind() {
+	case reflect.String:
+		rv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return emptyValue, err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, tp.Bits())
+		if err != nil {
+			return emptyValue, err
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, tp.Bits())
+		if err != nil {
+			return emptyValue, err
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, tp.Bits())
+		if err != nil {
+			return emptyValue, err
+		}
+		rv.SetFloat(f)
+	default:
+		return emptyValue, errors.New("unsupported type: " + tp.String())
+	}
+	return rv, nil
+}
+
+// convertValue returns a value of v which can be set to the given type.
+// v is converted only between types of the same kind, like a named type and its underlying type.
+func convertValue(v interface{}, tp reflect.Type) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return emptyValue, errors.New("nil is not assignable to " + tp.String())
+	}
+	if rv.Type().AssignableTo(tp) {
+		return rv, nil
+	}
+	if rv.Kind() == tp.Kind() && rv.Type().ConvertibleTo(tp) {
+		return rv.Convert(tp), nil
+	}
+	return emptyValue, errors.New(rv.Type().String() + " is not assignable to " + tp.String())
+}
+
+// adaptValue returns v as a value of tp by taking its address or dereferencing it if needed.
+func adaptValue(v interface{}, tp reflect.Type) reflect.Value {
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(tp) {
+		return rv
+	}
+	if tp.Kind() == reflect.Ptr && rv.Type().AssignableTo(tp.Elem()) {
+		ptr := reflect.New(tp.Elem())
+		ptr.Elem().Set(rv)
+		return ptr
+	}
+	if rv.Kind() == reflect.Ptr && rv.Type().Elem().AssignableTo(tp) {
+		return rv.Elem()
+	}
+	return rv
+}
+
 func setValueWithAttrPath(inst *reflect.Value, tp reflect.Type, attr string, v interface{}) bool {
 	attrs := strings.Split(attr, ".")
 	if len(attrs) <= 1 {